@@ -0,0 +1,98 @@
+// Package ass exports PGS subtitles to Advanced SubStation Alpha with
+// references to rendered images.
+package ass
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+const header = `[Script Info]
+ScriptType: v4.00+
+PlayResX: %d
+PlayResY: %d
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,48,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,0,0,7,0,0,0,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// ToASS reads the stream, writes the content of each shown display set
+// as a PNG in pngDir, and writes an ASS script with an event for each
+// that positions the image at its exact location in the frame.
+//
+// ASS has no standard way to display images, so each event uses the
+// \img(file) extension tag, which renderers that do not support it
+// ignore, along with \an7\pos(x,y) giving the top left corner of the
+// image. The script resolution, PlayResX and PlayResY, is set to the
+// video dimensions of the first shown composition, so that positions are
+// in the same pixel coordinates as PGS.
+func ToASS(r *pgs.Reader, pngDir string, w io.Writer) error {
+	intervals, err := pgs.Intervals(r)
+	if err != nil {
+		return err
+	}
+	var width, height uint16
+	if len(intervals) != 0 {
+		width, height = intervals[0].DisplaySet.Width, intervals[0].DisplaySet.Height
+	}
+	if err := os.MkdirAll(pngDir, 0755); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, header, width, height)
+	for i, iv := range intervals {
+		name := fmt.Sprintf("sub_%d.png", i+1)
+		bounds, err := writeImage(filepath.Join(pngDir, name), iv.DisplaySet)
+		if err != nil {
+			return fmt.Errorf("display set at %s: %w", iv.Start, err)
+		}
+		if bounds.Empty() {
+			continue
+		}
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Default,,0,0,0,,{\\an7\\pos(%d,%d)\\img(%s)}\n",
+			formatTime(iv.Start), formatTime(iv.End), bounds.Min.X, bounds.Min.Y, name)
+	}
+	return bw.Flush()
+}
+
+// writeImage renders the display set and writes its content to a PNG
+// file. Nothing is written when the display set has no visible content.
+func writeImage(filename string, ds *pgs.DisplaySet) (image.Rectangle, error) {
+	img, err := ds.Render()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	bounds, err := ds.ContentBounds()
+	if err != nil || bounds.Empty() {
+		return bounds, err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img.SubImage(bounds)); err != nil {
+		return image.Rectangle{}, err
+	}
+	return bounds, f.Close()
+}
+
+// formatTime formats a duration as H:MM:SS.cc with centisecond
+// precision.
+func formatTime(d time.Duration) string {
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
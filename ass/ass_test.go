@@ -0,0 +1,57 @@
+package ass
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestToASS(t *testing.T) {
+	// The second subtitle shows the object of the first again
+	sup := pgstest.BuildStream(pgstest.Subtitles(2), pgstest.ReuseObjects(),
+		pgstest.FrameSize(640, 360), pgstest.ObjectSize(40, 10))
+	dir := t.TempDir()
+	var b bytes.Buffer
+	if err := ToASS(pgs.NewReader(bytes.NewReader(sup)), dir, &b); err != nil {
+		t.Fatal(err)
+	}
+	script := b.String()
+	if !strings.Contains(script, "PlayResX: 640\nPlayResY: 360\n") {
+		t.Errorf("script resolution not 640x360:\n%s", script)
+	}
+	want := "Dialogue: 0,0:00:01.00,0:00:03.00,Default,,0,0,0,,{\\an7\\pos(300,332)\\img(sub_1.png)}\n" +
+		"Dialogue: 0,0:00:04.00,0:00:06.00,Default,,0,0,0,,{\\an7\\pos(300,332)\\img(sub_2.png)}\n"
+	if !strings.HasSuffix(script, want) {
+		t.Errorf("got events:\n%s\nwant:\n%s", script[strings.Index(script, "[Events]"):], want)
+	}
+	var imgs []image.Image
+	for _, name := range []string{"sub_1.png", "sub_2.png"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if r := img.Bounds(); r.Dx() != 40 || r.Dy() != 10 {
+			t.Errorf("%s: image %dx%d, want 40x10", name, r.Dx(), r.Dy())
+		}
+		imgs = append(imgs, img)
+	}
+	for y := range 10 {
+		for x := range 40 {
+			if imgs[0].At(x, y) != imgs[1].At(x, y) {
+				t.Fatalf("reused object differs at (%d, %d)", x, y)
+			}
+		}
+	}
+}
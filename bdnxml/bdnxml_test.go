@@ -0,0 +1,58 @@
+package bdnxml
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	want, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := make([]*pgs.DisplaySet, len(want))
+	for i := range want {
+		sets[i] = &want[i]
+	}
+	dir := t.TempDir()
+	if err := WriteBDN(dir, sets, 23.976); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBDN(filepath.Join(dir, Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d display sets, want %d", len(got), len(want))
+	}
+	frame := time.Second * 1001 / 24000
+	for i := range got {
+		g, w := &got[i], &want[i]
+		if d := g.PresentationTime - w.PresentationTime; d <= -frame || d >= frame {
+			t.Errorf("display set %d: presented at %s, want %s", i, g.PresentationTime, w.PresentationTime)
+		}
+		if g.Width != w.Width || g.Height != w.Height || g.FrameRate != w.FrameRate {
+			t.Errorf("display set %d: %dx%d frame at rate %v, want %dx%d at %v", i, g.Width, g.Height, g.FrameRate, w.Width, w.Height, w.FrameRate)
+		}
+		if g.IsClear() != w.IsClear() {
+			t.Errorf("display set %d: clear %t, want %t", i, g.IsClear(), w.IsClear())
+			continue
+		}
+		gb, err := g.ContentBounds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wb, err := w.ContentBounds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gb != wb {
+			t.Errorf("display set %d: content at %v, want %v", i, gb, wb)
+		}
+	}
+}
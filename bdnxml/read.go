@@ -0,0 +1,135 @@
+package bdnxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// ReadBDN reads the BDN XML document at path and the PNG images of its
+// graphics, relative to its directory, and returns a stream of display
+// sets for writing with pgs.Writer. Each event is an EpochStart showing
+// its graphics, composited into one object, followed by a clear at its
+// out time, unless the next event starts then. The video dimensions and
+// frame rate code are taken from the format of the document. Graphics
+// must have at most 255 colors, with transparent pixels in any color.
+func ReadBDN(path string) ([]pgs.DisplaySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc bdn
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	f := doc.Description.Format
+	fps, err := strconv.ParseFloat(f.FrameRate, 64)
+	if err != nil {
+		return nil, fmt.Errorf("frame rate %q: %w", f.FrameRate, err)
+	}
+	rate, _ := pgs.FrameRateCode(fps)
+	width, height, err := frameSize(f.VideoFormat)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	var stream []pgs.DisplaySet
+	for i, ev := range doc.Events {
+		in, err := pgs.ParseTimecode(ev.InTC, fps)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i+1, err)
+		}
+		out, err := pgs.ParseTimecode(ev.OutTC, fps)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i+1, err)
+		}
+		if out <= in {
+			return nil, fmt.Errorf("event %d: out %s not after in %s", i+1, ev.OutTC, ev.InTC)
+		}
+		if n := len(stream); n != 0 && in < stream[n-1].PresentationTime {
+			return nil, fmt.Errorf("event %d: in %s before previous event", i+1, ev.InTC)
+		}
+		show, err := readEvent(dir, &ev, in)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i+1, err)
+		}
+		// A clear at the same time as the show is replaced by it
+		if n := len(stream); n != 0 && stream[n-1].PresentationTime == in && stream[n-1].IsClear() {
+			stream = stream[:n-1]
+		}
+		stream = append(stream, *show, *pgs.NewClearDisplaySet(out, 0))
+	}
+	for i := range stream {
+		ds := &stream[i]
+		ds.Width, ds.Height = uint16(width), uint16(height)
+		ds.FrameRate = rate
+		ds.CompositionNumber = uint16(i)
+	}
+	return stream, nil
+}
+
+// readEvent reads the graphics of the event and composites them into
+// the object of a display set shown at t.
+func readEvent(dir string, ev *event, t time.Duration) (*pgs.DisplaySet, error) {
+	if len(ev.Graphics) == 0 {
+		return nil, errors.New("no graphics")
+	}
+	var bounds image.Rectangle
+	imgs := make([]image.Image, len(ev.Graphics))
+	for i, g := range ev.Graphics {
+		img, err := readPNG(filepath.Join(dir, strings.TrimSpace(g.Filename)))
+		if err != nil {
+			return nil, fmt.Errorf("graphic %d: %w", i+1, err)
+		}
+		imgs[i] = img
+		bounds = bounds.Union(image.Rect(g.X, g.Y, g.X+img.Bounds().Dx(), g.Y+img.Bounds().Dy()))
+	}
+	canvas := image.NewNRGBA(bounds)
+	for i, g := range ev.Graphics {
+		r := image.Rect(g.X, g.Y, g.X+imgs[i].Bounds().Dx(), g.Y+imgs[i].Bounds().Dy())
+		draw.Draw(canvas, r, imgs[i], imgs[i].Bounds().Min, draw.Over)
+	}
+	rle, p, err := pgs.EncodeImage(canvas)
+	if err != nil {
+		return nil, err
+	}
+	pimg, err := rle.Convert(p)
+	if err != nil {
+		return nil, err
+	}
+	return pgs.NewDisplaySet(pimg, bounds.Min.X, bounds.Min.Y, t)
+}
+
+func readPNG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// frameSize returns the frame dimensions of a BDN XML video format.
+func frameSize(format string) (width, height int, err error) {
+	switch format {
+	case "1080i", "1080p":
+		return 1920, 1080, nil
+	case "720p":
+		return 1280, 720, nil
+	case "576i":
+		return 720, 576, nil
+	case "480i", "480p":
+		return 720, 480, nil
+	}
+	return 0, 0, fmt.Errorf("unrecognized video format %q", format)
+}
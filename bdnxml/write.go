@@ -0,0 +1,242 @@
+// Package bdnxml converts PGS subtitles to and from BDN XML with a PNG
+// image per graphic, the interchange format of BluRay subtitle authoring
+// tools.
+package bdnxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Filename is the name of the BDN XML document written to a directory.
+const Filename = "bdn.xml"
+
+type bdn struct {
+	XMLName     xml.Name    `xml:"BDN"`
+	Version     string      `xml:"Version,attr"`
+	XmlnsXSI    string      `xml:"xmlns:xsi,attr"`
+	Schema      string      `xml:"xsi:noNamespaceSchemaLocation,attr"`
+	Description description `xml:"Description"`
+	Events      []event     `xml:"Events>Event"`
+}
+
+type description struct {
+	Name     name     `xml:"Name"`
+	Language language `xml:"Language"`
+	Format   format   `xml:"Format"`
+	Events   events   `xml:"Events"`
+}
+
+type name struct {
+	Title   string `xml:"Title,attr"`
+	Content string `xml:"Content,attr"`
+}
+
+type language struct {
+	Code string `xml:"Code,attr"`
+}
+
+type format struct {
+	VideoFormat string `xml:"VideoFormat,attr"`
+	FrameRate   string `xml:"FrameRate,attr"`
+	DropFrame   string `xml:"DropFrame,attr"`
+}
+
+type events struct {
+	Type           string `xml:"Type,attr"`
+	FirstEventInTC string `xml:"FirstEventInTC,attr"`
+	LastEventOutTC string `xml:"LastEventOutTC,attr"`
+	NumberofEvents int    `xml:"NumberofEvents,attr"`
+}
+
+type event struct {
+	InTC     string    `xml:"InTC,attr"`
+	OutTC    string    `xml:"OutTC,attr"`
+	Forced   string    `xml:"Forced,attr"`
+	Graphics []graphic `xml:"Graphic"`
+}
+
+type graphic struct {
+	Width    int    `xml:"Width,attr"`
+	Height   int    `xml:"Height,attr"`
+	X        int    `xml:"X,attr"`
+	Y        int    `xml:"Y,attr"`
+	Filename string `xml:",chardata"`
+}
+
+// WriteBDN writes the display sets to dir as a BDN XML document, named
+// Filename, and a PNG image per graphic. Each display set showing
+// objects begins an event that lasts until the next display set clears
+// or replaces it, with palette updates continuing the event, as with
+// pgs.Intervals, so clears close events rather than being written.
+// Each composition object of an event is a graphic, cropped to its
+// visible pixels. Objects and palettes may be defined by earlier display
+// sets of the epoch. Timecodes are HH:MM:SS:FF at fps frames per second,
+// as with pgs.Timecode, and events shorter than a frame are dropped.
+func WriteBDN(dir string, sets []*pgs.DisplaySet, fps float64) error {
+	if !(fps > 0) {
+		return fmt.Errorf("invalid frame rate: %g", fps)
+	}
+	doc := bdn{
+		Version:  "0.93",
+		XmlnsXSI: "http://www.w3.org/2001/XMLSchema-instance",
+		Schema:   "BD-03-006-0093b BDN File Format.xsd",
+		Description: description{
+			Name:     name{Title: "transup"},
+			Language: language{Code: "und"},
+			Format:   format{FrameRate: frameRate(fps), DropFrame: "False"},
+			Events:   events{Type: "Graphic"},
+		},
+	}
+	var palettes map[uint8]*pgs.Palette
+	var objects map[uint16]*pgs.Object
+	var open *pgs.DisplaySet
+	closeEvent := func(end *pgs.DisplaySet) error {
+		if open == nil {
+			return nil
+		}
+		ds := open
+		open = nil
+		in, out := pgs.Timecode(ds.PresentationTime, fps), pgs.Timecode(end.PresentationTime, fps)
+		if in == out {
+			return nil
+		}
+		ev := event{InTC: in, OutTC: out, Forced: "False"}
+		for i, co := range ds.Objects {
+			name := fmt.Sprintf("%04d_%d.png", len(doc.Events)+1, i+1)
+			g, ok, err := writeGraphic(filepath.Join(dir, name), ds, co, palettes, objects)
+			if err != nil {
+				return fmt.Errorf("display set at %s: composition object %d/%d: %w", ds.PresentationTime, i+1, len(ds.Objects), err)
+			}
+			if ok {
+				g.Filename = name
+				ev.Graphics = append(ev.Graphics, g)
+			}
+		}
+		if len(ev.Graphics) != 0 {
+			doc.Events = append(doc.Events, ev)
+		}
+		return nil
+	}
+	for _, ds := range sets {
+		if palettes == nil || ds.CompositionState == pgs.EpochStart {
+			palettes = make(map[uint8]*pgs.Palette)
+			objects = make(map[uint16]*pgs.Object)
+		}
+		if ds.Palette != nil {
+			palettes[ds.Palette.ID] = ds.Palette
+		}
+		if ds.Object != nil {
+			objects[ds.Object.ID] = ds.Object
+		}
+		if doc.Description.Format.VideoFormat == "" && ds.Height != 0 {
+			doc.Description.Format.VideoFormat = videoFormat(int(ds.Height), fps)
+		}
+		if open != nil && ds.PaletteUpdate && !ds.IsClear() {
+			continue
+		}
+		if err := closeEvent(ds); err != nil {
+			return err
+		}
+		if !ds.IsClear() {
+			open = ds
+		}
+	}
+	if len(sets) != 0 {
+		if err := closeEvent(sets[len(sets)-1]); err != nil {
+			return err
+		}
+	}
+	if n := len(doc.Events); n != 0 {
+		doc.Description.Events.FirstEventInTC = doc.Events[0].InTC
+		doc.Description.Events.LastEventOutTC = doc.Events[n-1].OutTC
+		doc.Description.Events.NumberofEvents = n
+	}
+	f, err := os.Create(filepath.Join(dir, Filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := encode(f, &doc); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func encode(w io.Writer, doc *bdn) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeGraphic renders the composition object alone and writes its
+// visible pixels to a PNG file. Nothing is written when it has no
+// visible pixels.
+func writeGraphic(filename string, ds *pgs.DisplaySet, co pgs.CompositionObject,
+	palettes map[uint8]*pgs.Palette, objects map[uint16]*pgs.Object) (graphic, bool, error) {
+	one := *ds
+	one.Objects = []pgs.CompositionObject{co}
+	one.Palette, one.Object = palettes[ds.PaletteID], objects[co.ObjectID]
+	if one.Palette == nil {
+		return graphic{}, false, fmt.Errorf("palette %d not defined in epoch", ds.PaletteID)
+	}
+	if one.Object == nil {
+		return graphic{}, false, fmt.Errorf("object %d not defined in epoch", co.ObjectID)
+	}
+	img, err := one.Render()
+	if err != nil {
+		return graphic{}, false, err
+	}
+	bounds, err := one.ContentBounds()
+	if err != nil || bounds.Empty() {
+		return graphic{}, false, err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return graphic{}, false, err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img.SubImage(bounds)); err != nil {
+		return graphic{}, false, err
+	}
+	g := graphic{Width: bounds.Dx(), Height: bounds.Dy(), X: bounds.Min.X, Y: bounds.Min.Y}
+	return g, true, f.Close()
+}
+
+// frameRate formats the frame rate as in BDN XML, rounded to at most
+// three decimal places, as in 23.976 and 29.97.
+func frameRate(fps float64) string {
+	return strconv.FormatFloat(math.Round(fps*1000)/1000, 'f', -1, 64)
+}
+
+// videoFormat returns the BDN XML video format of frames of the height,
+// with 1080 line frames at 25 and 29.97 fps interlaced.
+func videoFormat(height int, fps float64) string {
+	switch {
+	case height >= 1080:
+		if math.Abs(fps-25) < 0.01 || math.Abs(fps-30000.0/1001) < 0.01 {
+			return "1080i"
+		}
+		return "1080p"
+	case height >= 720:
+		return "720p"
+	case height >= 576:
+		return "576i"
+	}
+	return "480i"
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"image/png"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/andrewarchi/transup/pgs"
@@ -13,16 +18,52 @@ import (
 
 const usage = `Usage:
 	transup reverse <filename> <duration> [out]
-	transup dump <filename> <image-dir>`
+	transup shift <filename> <offset> [out]
+	transup stretch <filename> <from-fps> <to-fps> [out]
+	transup retime <filename> <out> <from>=<to>...
+	transup trim <filename> <from> <to> [out]
+	transup cut <filename> <from> <to> [out]
+	transup split <filename> <time> <out1> <out2>
+	transup concat <filename> <filename2> <offset> [out]
+	transup forced <filename> [out]
+	transup dump <filename> <image-dir>
+	transup verify <filename>
+	transup check <filename>`
 
 func main() {
-	if len(os.Args) < 4 || len(os.Args) > 5 ||
-		!(os.Args[1] == "reverse" ||
-			(os.Args[1] == "dump" && len(os.Args) == 4)) {
+	if len(os.Args) < 3 ||
+		!((os.Args[1] == "reverse" && (len(os.Args) == 4 || len(os.Args) == 5)) ||
+			(os.Args[1] == "shift" && (len(os.Args) == 4 || len(os.Args) == 5)) ||
+			(os.Args[1] == "stretch" && (len(os.Args) == 5 || len(os.Args) == 6)) ||
+			(os.Args[1] == "retime" && len(os.Args) >= 5) ||
+			((os.Args[1] == "trim" || os.Args[1] == "cut") && (len(os.Args) == 5 || len(os.Args) == 6)) ||
+			(os.Args[1] == "split" && len(os.Args) == 6) ||
+			(os.Args[1] == "concat" && (len(os.Args) == 5 || len(os.Args) == 6)) ||
+			(os.Args[1] == "forced" && (len(os.Args) == 3 || len(os.Args) == 4)) ||
+			(os.Args[1] == "dump" && len(os.Args) == 4) ||
+			(os.Args[1] == "verify" && len(os.Args) == 3) ||
+			(os.Args[1] == "check" && len(os.Args) == 3)) {
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(2)
 	}
 	cmd, filename := os.Args[1], os.Args[2]
+	switch cmd {
+	case "verify":
+		verify(filename)
+		return
+	case "check":
+		check(filename)
+		return
+	case "shift", "stretch", "retime", "trim", "cut", "forced":
+		transform(cmd, filename, os.Args[3:])
+		return
+	case "split":
+		split(filename, os.Args[3], os.Args[4], os.Args[5])
+		return
+	case "concat":
+		concat(filename, os.Args[3], os.Args[4:])
+		return
+	}
 
 	f, err := os.Open(filename)
 	try(err)
@@ -50,17 +91,9 @@ func main() {
 			if i != 0 {
 				fmt.Println()
 			}
-			fmt.Printf("Presentation: %s Decoding:%s\n", ds.PresentationTime, ds.DecodingTime)
-			fmt.Printf("Composition: %+v\n", ds.PresentationComposition)
-			if ds.Windows != nil {
-				fmt.Printf("Windows: %+v\n", ds.Windows)
-			}
-			if ds.Palette != nil {
-				fmt.Printf("Palette: %+v\n", ds.Palette)
-			}
+			printDisplaySet(&ds)
 			if ds.Object != nil {
 				n++
-				fmt.Printf("Object: %+v\n", ds.Object)
 				img, err := ds.Object.Image.Convert(ds.Palette)
 				try(err)
 				name := fmt.Sprintf("sub_%d_%s.png", n, ds.PresentationTime)
@@ -72,6 +105,195 @@ func main() {
 	}
 }
 
+// verify reads the file, re-encodes each display set, and compares it
+// byte-for-byte with the original.
+func verify(filename string) {
+	data, err := os.ReadFile(filename)
+	try(err)
+	br := bytes.NewReader(data)
+	r := pgs.NewReader(br)
+	var buf bytes.Buffer
+	w := pgs.NewWriter(&buf)
+	for i := 0; ; i++ {
+		start := len(data) - br.Len()
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		try(err)
+		raw := data[start : len(data)-br.Len()]
+		buf.Reset()
+		try(w.Write(ds))
+		enc := buf.Bytes()
+		if bytes.Equal(raw, enc) {
+			continue
+		}
+		off := 0
+		for off < len(raw) && off < len(enc) && raw[off] == enc[off] {
+			off++
+		}
+		fmt.Printf("Display set %d differs at offset %d (0x%x)\n", i, start+off, start+off)
+		fmt.Println("\nRead:")
+		printDisplaySet(ds)
+		fmt.Println("\nWritten:")
+		ds2, err := pgs.NewReader(&buf).Read()
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			printDisplaySet(ds2)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// check validates the file, printing its diagnostics, and exits with
+// status 1 if any is an error.
+func check(filename string) {
+	f, err := os.Open(filename)
+	try(err)
+	defer f.Close()
+	diags, err := pgs.Validate(bufio.NewReader(f))
+	try(err)
+	failed := false
+	for _, d := range diags {
+		fmt.Println(d)
+		failed = failed || d.Severity == pgs.Error
+	}
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// transform streams the file through the retiming, editing, or
+// filtering transform of cmd.
+func transform(cmd, filename string, args []string) {
+	var run func(r *pgs.Reader, w *pgs.Writer) error
+	var outName string
+	switch cmd {
+	case "shift":
+		offset, err := time.ParseDuration(args[0])
+		try(err)
+		run = func(r *pgs.Reader, w *pgs.Writer) error { return trans.Shift(r, w, offset) }
+		args = args[1:]
+	case "stretch":
+		from, err := strconv.ParseFloat(args[0], 64)
+		try(err)
+		to, err := strconv.ParseFloat(args[1], 64)
+		try(err)
+		run = func(r *pgs.Reader, w *pgs.Writer) error { return trans.Stretch(r, w, from, to) }
+		args = args[2:]
+	case "retime":
+		outName = args[0]
+		var anchors []trans.Anchor
+		for _, arg := range args[1:] {
+			from, to, ok := strings.Cut(arg, "=")
+			if !ok {
+				try(fmt.Errorf("anchor not <from>=<to>: %q", arg))
+			}
+			var a trans.Anchor
+			var err error
+			a.From, err = time.ParseDuration(from)
+			try(err)
+			a.To, err = time.ParseDuration(to)
+			try(err)
+			anchors = append(anchors, a)
+		}
+		fn, err := trans.AnchorMap(anchors)
+		try(err)
+		run = func(r *pgs.Reader, w *pgs.Writer) error { return trans.Retime(r, w, fn) }
+		args = nil
+	case "trim", "cut":
+		from, err := time.ParseDuration(args[0])
+		try(err)
+		to, err := time.ParseDuration(args[1])
+		try(err)
+		edit := trans.Trim
+		if cmd == "cut" {
+			edit = trans.Cut
+		}
+		run = func(r *pgs.Reader, w *pgs.Writer) error { return edit(r, w, from, to) }
+		args = args[2:]
+	case "forced":
+		run = func(r *pgs.Reader, w *pgs.Writer) error {
+			_, err := trans.ForcedOnly(r, w)
+			return err
+		}
+	}
+	if len(args) != 0 {
+		outName = args[0]
+	}
+
+	f, err := os.Open(filename)
+	try(err)
+	defer f.Close()
+	out := os.Stdout
+	if outName != "" {
+		out, err = os.Create(outName)
+		try(err)
+		defer out.Close()
+	}
+	bw := bufio.NewWriter(out)
+	try(run(pgs.NewReader(bufio.NewReader(f)), pgs.NewWriter(bw)))
+	try(bw.Flush())
+}
+
+// split splits the file at t into two files.
+func split(filename, t, out1, out2 string) {
+	d, err := time.ParseDuration(t)
+	try(err)
+	f, err := os.Open(filename)
+	try(err)
+	defer f.Close()
+	o1, err := os.Create(out1)
+	try(err)
+	defer o1.Close()
+	o2, err := os.Create(out2)
+	try(err)
+	defer o2.Close()
+	bw1, bw2 := bufio.NewWriter(o1), bufio.NewWriter(o2)
+	try(trans.Split(pgs.NewReader(bufio.NewReader(f)), pgs.NewWriter(bw1), pgs.NewWriter(bw2), d))
+	try(bw1.Flush())
+	try(bw2.Flush())
+}
+
+// concat joins the files, offsetting the second.
+func concat(filename, filename2 string, args []string) {
+	offset, err := time.ParseDuration(args[0])
+	try(err)
+	f1, err := os.Open(filename)
+	try(err)
+	defer f1.Close()
+	f2, err := os.Open(filename2)
+	try(err)
+	defer f2.Close()
+	out := os.Stdout
+	if len(args) == 2 {
+		out, err = os.Create(args[1])
+		try(err)
+		defer out.Close()
+	}
+	bw := bufio.NewWriter(out)
+	r1, r2 := pgs.NewReader(bufio.NewReader(f1)), pgs.NewReader(bufio.NewReader(f2))
+	try(trans.Concat(r1, r2, pgs.NewWriter(bw), offset))
+	try(bw.Flush())
+}
+
+func printDisplaySet(ds *pgs.DisplaySet) {
+	fmt.Printf("Presentation: %s Decoding:%s\n", ds.PresentationTime, ds.DecodingTime)
+	fmt.Printf("Composition: %+v\n", ds.PresentationComposition)
+	if ds.Windows != nil {
+		fmt.Printf("Windows: %+v\n", ds.Windows)
+	}
+	if ds.Palette != nil {
+		fmt.Printf("Palette: %+v\n", ds.Palette)
+	}
+	if ds.Object != nil {
+		fmt.Printf("Object: %+v\n", ds.Object)
+	}
+}
+
 func try(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,349 @@
+package mkv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Track is a PGS subtitle track of a Matroska file.
+type Track struct {
+	Number uint64
+	pgs.TrackInfo
+	compression *compression
+}
+
+// compression is the content compression of the blocks of a track.
+type compression struct {
+	algo    uint64
+	setting []byte // Bytes stripped from the start of each block
+}
+
+const (
+	compZlib          = 0
+	compHeaderStrip   = 3
+	defaultLanguage   = "eng" // Matroska default when a track has no language
+	blockLacingFlags  = 0x06
+	ticksPerSecond90k = 90000
+)
+
+// File is a Matroska file opened for demuxing its PGS tracks. It reads
+// the file once, without seeking, so only one track can be read.
+type File struct {
+	r      *bufio.Reader
+	Tracks []Track // PGS tracks, in the order of the file
+	scale  uint64  // Nanoseconds per timestamp tick
+	read   bool
+}
+
+// Open reads the headers of a Matroska file up to its first cluster and
+// returns the file with its PGS tracks. Tracks without a language have
+// the Matroska default of "eng".
+func Open(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+	id, size, err := readElementHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("EBML header: %w", err)
+	}
+	if id != idEBML {
+		return nil, errors.New("not an EBML file")
+	}
+	if _, err := readElementData(br, size); err != nil {
+		return nil, fmt.Errorf("EBML header: %w", err)
+	}
+	id, size, err = readElementHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("segment: %w", unexpectedEOF(err))
+	}
+	if id != idSegment {
+		return nil, fmt.Errorf("element 0x%x not a segment", id)
+	}
+	f := &File{r: br, scale: defaultTimestampScale}
+	if size != unknownSize {
+		f.r = bufio.NewReader(io.LimitReader(br, size))
+	}
+	tracks := false
+	for {
+		id, size, err := readElementHeader(f.r)
+		if err == io.EOF {
+			return nil, errors.New("no clusters")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("segment: %w", err)
+		}
+		if id == idCluster {
+			// Clusters are read child by child, so unknown sizes are
+			// allowed and the size is not needed
+			if !tracks {
+				return nil, errors.New("cluster before tracks")
+			}
+			return f, nil
+		}
+		if id != idInfo && id != idTracks {
+			if size == unknownSize {
+				return nil, fmt.Errorf("element 0x%x of unknown size", id)
+			}
+			if _, err := io.CopyN(io.Discard, f.r, size); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			continue
+		}
+		data, err := readElementData(f.r, size)
+		if err != nil {
+			return nil, err
+		}
+		if id == idInfo {
+			if f.scale, err = timestampScale(data); err != nil {
+				return nil, fmt.Errorf("info: %w", err)
+			}
+			continue
+		}
+		if f.Tracks, err = pgsTracks(data); err != nil {
+			return nil, fmt.Errorf("tracks: %w", err)
+		}
+		tracks = true
+	}
+}
+
+// pgsTracks returns the PGS tracks in the data of a tracks element.
+func pgsTracks(tracks []byte) ([]Track, error) {
+	var pgsTracks []Track
+	for len(tracks) != 0 {
+		id, entry, rest, err := nextChild(tracks)
+		if err != nil {
+			return nil, err
+		}
+		tracks = rest
+		if id != idTrackEntry {
+			continue
+		}
+		t := Track{TrackInfo: pgs.TrackInfo{Language: defaultLanguage, Default: true}}
+		var codec string
+		for len(entry) != 0 {
+			id, data, rest, err := nextChild(entry)
+			if err != nil {
+				return nil, err
+			}
+			entry = rest
+			switch id {
+			case idTrackNumber, idFlagDefault, idFlagForced:
+				v, err := readUint(data)
+				if err != nil {
+					return nil, err
+				}
+				switch id {
+				case idTrackNumber:
+					t.Number = v
+				case idFlagDefault:
+					t.Default = v != 0
+				case idFlagForced:
+					t.Forced = v != 0
+				}
+			case idCodecID:
+				codec = string(data)
+			case idLanguage:
+				t.Language = string(data)
+			case idName:
+				t.Name = string(data)
+			case idContentEncodings:
+				if t.compression, err = contentCompression(data); err != nil {
+					return nil, fmt.Errorf("track %d: %w", t.Number, err)
+				}
+			}
+		}
+		if codec == CodecID {
+			pgsTracks = append(pgsTracks, t)
+		}
+	}
+	return pgsTracks, nil
+}
+
+// contentCompression returns the compression in the data of a content
+// encodings element, or nil if the content is not compressed. Encrypted
+// content and multiple encodings are not supported.
+func contentCompression(encodings []byte) (*compression, error) {
+	var comp *compression
+	for len(encodings) != 0 {
+		id, encoding, rest, err := nextChild(encodings)
+		if err != nil {
+			return nil, err
+		}
+		encodings = rest
+		if id != idContentEncoding {
+			continue
+		}
+		if comp != nil {
+			return nil, errors.New("multiple content encodings")
+		}
+		for len(encoding) != 0 {
+			id, data, rest, err := nextChild(encoding)
+			if err != nil {
+				return nil, err
+			}
+			encoding = rest
+			switch id {
+			case idContentEncryption:
+				return nil, errors.New("encrypted content")
+			case idContentCompression:
+				comp = &compression{algo: compZlib}
+				if v, err := findUint(data, idContentCompAlgo); err == nil {
+					comp.algo = v
+				} else if err != errNotFound {
+					return nil, err
+				}
+				if comp.algo != compZlib && comp.algo != compHeaderStrip {
+					return nil, fmt.Errorf("unsupported compression algorithm %d", comp.algo)
+				}
+				for d := data; len(d) != 0; {
+					id, setting, rest, err := nextChild(d)
+					if err != nil {
+						return nil, err
+					}
+					if id == idContentCompSetting {
+						comp.setting = setting
+					}
+					d = rest
+				}
+			}
+		}
+	}
+	return comp, nil
+}
+
+// TrackReader returns a reader of the blocks of the PGS track with the
+// given number as a .sup stream, for pgs.NewReader. Each segment is given
+// the presentation time of its block, truncated to 32 bits, and the same
+// decoding time, since Matroska does not store decoding times. Reading
+// consumes the file, so it can be called only once.
+func (f *File) TrackReader(number uint64) (io.Reader, error) {
+	if f.read {
+		return nil, errors.New("track already read")
+	}
+	for i := range f.Tracks {
+		if f.Tracks[i].Number == number {
+			f.read = true
+			return &trackReader{f: f, track: &f.Tracks[i]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no PGS track %d", number)
+}
+
+// trackReader reads the blocks of a track from the clusters of a file.
+type trackReader struct {
+	f         *File
+	track     *Track
+	clusterTS uint64
+	out       []byte
+	err       error
+}
+
+func (tr *trackReader) Read(b []byte) (int, error) {
+	for len(tr.out) == 0 {
+		if tr.err != nil {
+			return 0, tr.err
+		}
+		tr.err = tr.next()
+	}
+	n := copy(b, tr.out)
+	tr.out = tr.out[n:]
+	return n, nil
+}
+
+// next reads the next element of the clusters, entering clusters and
+// block groups to read their children, and converts the blocks of the
+// track.
+func (tr *trackReader) next() error {
+	r := tr.f.r
+	id, size, err := readElementHeader(r)
+	if err != nil {
+		return err
+	}
+	switch id {
+	case idCluster, idBlockGroup:
+		return nil
+	case idTimestamp, idSimpleBlock, idBlock:
+	default:
+		if size == unknownSize {
+			return fmt.Errorf("element 0x%x of unknown size", id)
+		}
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return unexpectedEOF(err)
+		}
+		return nil
+	}
+	data, err := readElementData(r, size)
+	if err != nil {
+		return err
+	}
+	if id == idTimestamp {
+		tr.clusterTS, err = readUint(data)
+		return err
+	}
+	return tr.block(data)
+}
+
+// block converts the segments of a block of the track, if it is one, to
+// .sup segments.
+func (tr *trackReader) block(data []byte) error {
+	br := bytes.NewReader(data)
+	number, err := readSize(br)
+	if err != nil {
+		return fmt.Errorf("block: %w", err)
+	}
+	if uint64(number) != tr.track.Number {
+		return nil
+	}
+	var h struct {
+		Timestamp int16
+		Flags     uint8
+	}
+	if err := binary.Read(br, binary.BigEndian, &h); err != nil {
+		return fmt.Errorf("block: %w", unexpectedEOF(err))
+	}
+	if h.Flags&blockLacingFlags != 0 {
+		return errors.New("block: laced blocks not supported")
+	}
+	payload := data[len(data)-br.Len():]
+	if c := tr.track.compression; c != nil {
+		if payload, err = c.decompress(payload); err != nil {
+			return fmt.Errorf("block: %w", err)
+		}
+	}
+	ts := max(int64(tr.clusterTS)+int64(h.Timestamp), 0)
+	ns := uint64(ts) * tr.f.scale
+	pts := uint32((ns*ticksPerSecond90k + 500000000) / 1000000000)
+	for len(payload) != 0 {
+		if len(payload) < 3 {
+			return errors.New("block: truncated segment header")
+		}
+		size := 3 + int(binary.BigEndian.Uint16(payload[1:3]))
+		if size > len(payload) {
+			return errors.New("block: segment exceeds block")
+		}
+		tr.out = append(tr.out, 'P', 'G')
+		tr.out = binary.BigEndian.AppendUint32(tr.out, pts)
+		tr.out = binary.BigEndian.AppendUint32(tr.out, pts)
+		tr.out = append(tr.out, payload[:size]...)
+		payload = payload[size:]
+	}
+	return nil
+}
+
+// decompress returns the data of a block before compression.
+func (c *compression) decompress(data []byte) ([]byte, error) {
+	if c.algo == compHeaderStrip {
+		return append(append([]byte(nil), c.setting...), data...), nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,208 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Element IDs, including their length marker bits.
+const (
+	idEBML               = 0x1a45dfa3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42f7
+	idEBMLMaxIDLength    = 0x42f2
+	idEBMLMaxSizeLength  = 0x42f3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+	idSegment            = 0x18538067
+	idSeekHead           = 0x114d9b74
+	idInfo               = 0x1549a966
+	idTimestampScale     = 0x2ad7b1
+	idMuxingApp          = 0x4d80
+	idWritingApp         = 0x5741
+	idTracks             = 0x1654ae6b
+	idTrackEntry         = 0xae
+	idTrackNumber        = 0xd7
+	idTrackUID           = 0x73c5
+	idTrackType          = 0x83
+	idFlagLacing         = 0x9c
+	idCodecID            = 0x86
+	idLanguage           = 0x22b59c
+	idName               = 0x536e
+	idFlagDefault        = 0x88
+	idFlagForced         = 0x55aa
+	idContentEncodings   = 0x6d80
+	idContentEncoding    = 0x6240
+	idContentCompression = 0x5034
+	idContentCompAlgo    = 0x4254
+	idContentCompSetting = 0x4255
+	idContentEncryption  = 0x5035
+	idCluster            = 0x1f43b675
+	idTimestamp          = 0xe7
+	idSimpleBlock        = 0xa3
+	idBlockGroup         = 0xa0
+	idBlock              = 0xa1
+	idCues               = 0x1c53bb6b
+	idVoid               = 0xec
+	idCRC32              = 0xbf
+)
+
+// unknownSize is the size of an element whose size is not known, as
+// written by streaming muxers.
+const unknownSize = -1
+
+// readID reads an element ID, keeping its length marker bits.
+func readID(r io.ByteReader) (uint32, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := leadingZeros(b) + 1
+	if n > 4 {
+		return 0, fmt.Errorf("invalid element ID byte: 0x%02x", b)
+	}
+	id := uint32(b)
+	for i := 1; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		id = id<<8 | uint32(b)
+	}
+	return id, nil
+}
+
+// readSize reads a variable length element size, which is unknownSize
+// when all of its value bits are set.
+func readSize(r io.ByteReader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	n := leadingZeros(b) + 1
+	if n > 8 {
+		return 0, fmt.Errorf("invalid element size byte: 0x%02x", b)
+	}
+	size := uint64(b) & (0xff >> n)
+	for i := 1; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		size = size<<8 | uint64(b)
+	}
+	if size == 1<<(7*n)-1 {
+		return unknownSize, nil
+	}
+	return int64(size), nil
+}
+
+// readElementHeader reads the ID and size of an element.
+func readElementHeader(r io.ByteReader) (id uint32, size int64, err error) {
+	if id, err = readID(r); err != nil {
+		return 0, 0, err
+	}
+	size, err = readSize(r)
+	return id, size, err
+}
+
+// readElementData reads the data of an element of known size.
+func readElementData(r io.Reader, size int64) ([]byte, error) {
+	if size == unknownSize {
+		return nil, errors.New("element of unknown size")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// nextChild splits the first child element from the data of a master
+// element.
+func nextChild(data []byte) (id uint32, payload, rest []byte, err error) {
+	r := bytes.NewReader(data)
+	id, size, err := readElementHeader(r)
+	if err != nil {
+		return 0, nil, nil, unexpectedEOF(err)
+	}
+	if size == unknownSize || size > int64(r.Len()) {
+		return 0, nil, nil, fmt.Errorf("child element 0x%x exceeds parent", id)
+	}
+	start := len(data) - r.Len()
+	return id, data[start : start+int(size)], data[start+int(size):], nil
+}
+
+func leadingZeros(b byte) int {
+	n := 0
+	for mask := byte(0x80); mask != 0 && b&mask == 0; mask >>= 1 {
+		n++
+	}
+	return n
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendID appends an element ID, which includes its length marker bits.
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id > 0xffffff:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xffff:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xff:
+		return append(b, byte(id>>8), byte(id))
+	}
+	return append(b, byte(id))
+}
+
+// appendSize appends a size as a variable length integer of the fewest
+// bytes.
+func appendSize(b []byte, size uint64) []byte {
+	n := 1
+	for n < 8 && size >= 1<<(7*n)-1 {
+		n++
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], size|1<<(7*n))
+	return append(b, buf[8-n:]...)
+}
+
+// appendElement appends an element with the given data.
+func appendElement(b []byte, id uint32, data []byte) []byte {
+	b = appendID(b, id)
+	b = appendSize(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendUint appends an unsigned integer element of the fewest bytes.
+func appendUint(b []byte, id uint32, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	n := 8
+	for n > 1 && buf[8-n] == 0 {
+		n--
+	}
+	return appendElement(b, id, buf[8-n:])
+}
+
+// readUint decodes the data of an unsigned integer element.
+func readUint(data []byte) (uint64, error) {
+	if len(data) > 8 {
+		return 0, fmt.Errorf("integer of %d bytes", len(data))
+	}
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
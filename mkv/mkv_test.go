@@ -0,0 +1,75 @@
+package mkv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestWriteMKVRoundTrip(t *testing.T) {
+	sup := pgstest.BuildStream(pgstest.Subtitles(3))
+	var b bytes.Buffer
+	if err := WriteMKV(pgs.NewReader(bytes.NewReader(sup)), &b, "fre"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Tracks) != 1 || f.Tracks[0].Language != "fre" {
+		t.Fatalf("got tracks %+v, want one French track", f.Tracks)
+	}
+	tr, err := f.TrackReader(f.Tracks[0].Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decoding times equal presentation times, which are whole
+	// milliseconds, so the stream survives exactly
+	if !bytes.Equal(got, sup) {
+		t.Errorf("demuxed %d bytes differing from the %d bytes muxed", len(got), len(sup))
+	}
+}
+
+func TestMuxIntoMKV(t *testing.T) {
+	sup1 := pgstest.BuildStream(pgstest.Subtitles(2))
+	sup2 := pgstest.BuildStream(pgstest.Subtitles(3), pgstest.Timing(1500*time.Millisecond, time.Second, 2*time.Second))
+	var in bytes.Buffer
+	if err := WriteMKV(pgs.NewReader(bytes.NewReader(sup1)), &in, "fre"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := MuxIntoMKV(bytes.NewReader(in.Bytes()), pgs.NewReader(bytes.NewReader(sup2)), &out, "ger"); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		lang string
+		sup  []byte
+	}{{"fre", sup1}, {"ger", sup2}} {
+		f, err := Open(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Tracks) != 2 || f.Tracks[i].Language != want.lang {
+			t.Fatalf("got tracks %+v, want French and German tracks", f.Tracks)
+		}
+		tr, err := f.TrackReader(f.Tracks[i].Number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.sup) {
+			t.Errorf("track %d: demuxed %d bytes differing from the %d bytes muxed", f.Tracks[i].Number, len(got), len(want.sup))
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Package mkv muxes and demuxes PGS streams carried in Matroska
+// containers.
+package mkv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// CodecID is the Matroska codec ID of PGS subtitle tracks.
+const CodecID = "S_HDMV/PGS"
+
+const (
+	trackTypeSubtitle      = 0x11
+	defaultTimestampScale  = 1000000 // Nanoseconds per timestamp tick
+	supHeaderPrefix        = 10      // "PG", PTS, and DTS of .sup segment headers
+	unknownSegmentSizeByte = 0x01    // Marker of an 8-byte size with all value bits set
+)
+
+// WriteMKV reads a PGS stream and writes it as the only track of a new
+// Matroska file, with the given ISO 639-2 language code, or "und" when
+// empty. Each display set is written as a block in its own cluster, with
+// its segments stored without the "PG" magic number and timestamps, as
+// is conventional for the S_HDMV/PGS codec. The file is written without
+// seeking, so the segment has an unknown size and there are no cues.
+func WriteMKV(sup *pgs.Reader, out io.Writer, lang string) error {
+	bw := bufio.NewWriter(out)
+	var b []byte
+	b = appendElement(b, idEBML, ebmlHeader())
+	b = appendSegmentStart(b)
+	var info []byte
+	info = appendUint(info, idTimestampScale, defaultTimestampScale)
+	info = appendElement(info, idMuxingApp, []byte("transup"))
+	info = appendElement(info, idWritingApp, []byte("transup"))
+	b = appendElement(b, idInfo, info)
+	b = appendElement(b, idTracks, trackEntry(1, 1, lang))
+	if _, err := bw.Write(b); err != nil {
+		return err
+	}
+	m := &muxer{w: bw, sup: sup, track: 1, scale: defaultTimestampScale}
+	if err := m.writeUntil(-1); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// MuxIntoMKV copies the Matroska file in, adding the PGS stream as a new
+// subtitle track with the given ISO 639-2 language code, or "und" when
+// empty. Each display set is written as a block in its own cluster,
+// placed before the first cluster of the file with a later timestamp, so
+// clusters stay in order. The seek head and cues, which hold positions
+// invalidated by the new clusters, are dropped, and the segment is
+// written with an unknown size, so the output is written without
+// seeking. Elements with an unknown size are not supported.
+func MuxIntoMKV(in io.ReadSeeker, sup *pgs.Reader, out io.Writer, lang string) error {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReader(in)
+	bw := bufio.NewWriter(out)
+	id, size, err := readElementHeader(br)
+	if err != nil {
+		return fmt.Errorf("EBML header: %w", err)
+	}
+	if id != idEBML {
+		return errors.New("not an EBML file")
+	}
+	header, err := readElementData(br, size)
+	if err != nil {
+		return fmt.Errorf("EBML header: %w", err)
+	}
+	id, size, err = readElementHeader(br)
+	if err != nil {
+		return fmt.Errorf("segment: %w", unexpectedEOF(err))
+	}
+	if id != idSegment {
+		return fmt.Errorf("element 0x%x not a segment", id)
+	}
+	if _, err := bw.Write(appendSegmentStart(appendElement(nil, idEBML, header))); err != nil {
+		return err
+	}
+	segment := bufio.NewReader(br)
+	if size != unknownSize {
+		segment = bufio.NewReader(io.LimitReader(br, size))
+	}
+
+	m := &muxer{w: bw, sup: sup, scale: defaultTimestampScale}
+	for {
+		id, size, err := readElementHeader(segment)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("segment: %w", err)
+		}
+		if size == unknownSize {
+			return fmt.Errorf("element 0x%x of unknown size", id)
+		}
+		switch id {
+		case idSeekHead, idCues, idVoid, idCRC32:
+			if _, err := io.CopyN(io.Discard, segment, size); err != nil {
+				return unexpectedEOF(err)
+			}
+			continue
+		case idInfo, idTracks, idCluster:
+		default:
+			b := appendSize(appendID(nil, id), uint64(size))
+			if _, err := bw.Write(b); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(bw, segment, size); err != nil {
+				return unexpectedEOF(err)
+			}
+			continue
+		}
+		data, err := readElementData(segment, size)
+		if err != nil {
+			return err
+		}
+		switch id {
+		case idInfo:
+			if m.scale, err = timestampScale(data); err != nil {
+				return fmt.Errorf("info: %w", err)
+			}
+		case idTracks:
+			number, uid, err := nextTrack(data)
+			if err != nil {
+				return fmt.Errorf("tracks: %w", err)
+			}
+			data = append(data, trackEntry(number, uid, lang)...)
+			m.track = number
+		case idCluster:
+			if m.track == 0 {
+				return errors.New("cluster before tracks")
+			}
+			ts, err := clusterTimestamp(data)
+			if err != nil {
+				return fmt.Errorf("cluster: %w", err)
+			}
+			if err := m.writeUntil(int64(ts)); err != nil {
+				return err
+			}
+		}
+		if _, err := bw.Write(appendElement(nil, id, data)); err != nil {
+			return err
+		}
+	}
+	if m.track == 0 {
+		return errors.New("no tracks")
+	}
+	if err := m.writeUntil(-1); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// muxer writes the display sets of a PGS stream as clusters.
+type muxer struct {
+	w       io.Writer
+	sup     *pgs.Reader
+	track   uint64
+	scale   uint64 // Nanoseconds per timestamp tick
+	pending *pgs.DisplaySet
+	done    bool
+}
+
+// writeUntil writes clusters for the display sets presented before the
+// timestamp limit, in ticks, or for all remaining display sets when the
+// limit is negative.
+func (m *muxer) writeUntil(limit int64) error {
+	for !m.done {
+		if m.pending == nil {
+			ds, err := m.sup.Read()
+			if err == io.EOF {
+				m.done = true
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("PGS stream: %w", err)
+			}
+			m.pending = ds
+		}
+		ts := uint64(m.pending.PresentationTime.Nanoseconds()) / m.scale
+		if limit >= 0 && int64(ts) >= limit {
+			return nil
+		}
+		if err := m.writeCluster(ts, m.pending); err != nil {
+			return err
+		}
+		m.pending = nil
+	}
+	return nil
+}
+
+// writeCluster writes a cluster with a single block of the display set.
+func (m *muxer) writeCluster(ts uint64, ds *pgs.DisplaySet) error {
+	var buf bytes.Buffer
+	if err := pgs.NewWriter(&buf).Write(ds); err != nil {
+		return fmt.Errorf("display set at %s: %w", ds.PresentationTime, err)
+	}
+	block := appendSize(nil, m.track)
+	block = append(block, 0, 0, 0x80) // Relative timestamp and keyframe flag
+	for seg := buf.Bytes(); len(seg) != 0; {
+		size := 13 + int(binary.BigEndian.Uint16(seg[11:13]))
+		block = append(block, seg[supHeaderPrefix:size]...)
+		seg = seg[size:]
+	}
+	cluster := appendUint(nil, idTimestamp, ts)
+	cluster = appendElement(cluster, idSimpleBlock, block)
+	_, err := m.w.Write(appendElement(nil, idCluster, cluster))
+	return err
+}
+
+// ebmlHeader returns the data of the EBML header of a Matroska file.
+func ebmlHeader() []byte {
+	var b []byte
+	b = appendUint(b, idEBMLVersion, 1)
+	b = appendUint(b, idEBMLReadVersion, 1)
+	b = appendUint(b, idEBMLMaxIDLength, 4)
+	b = appendUint(b, idEBMLMaxSizeLength, 8)
+	b = appendElement(b, idDocType, []byte("matroska"))
+	b = appendUint(b, idDocTypeVersion, 4)
+	return appendUint(b, idDocTypeReadVersion, 2)
+}
+
+// appendSegmentStart appends the ID and unknown size of a segment.
+func appendSegmentStart(b []byte) []byte {
+	b = appendID(b, idSegment)
+	return append(b, unknownSegmentSizeByte, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+}
+
+// trackEntry returns a track entry element for a PGS track.
+func trackEntry(number, uid uint64, lang string) []byte {
+	if lang == "" {
+		lang = "und"
+	}
+	var b []byte
+	b = appendUint(b, idTrackNumber, number)
+	b = appendUint(b, idTrackUID, uid)
+	b = appendUint(b, idTrackType, trackTypeSubtitle)
+	b = appendUint(b, idFlagLacing, 0)
+	b = appendElement(b, idCodecID, []byte(CodecID))
+	b = appendElement(b, idLanguage, []byte(lang))
+	return appendElement(nil, idTrackEntry, b)
+}
+
+// nextTrack returns a track number and UID greater than those of every
+// track entry in the data of a tracks element.
+func nextTrack(tracks []byte) (number, uid uint64, err error) {
+	for len(tracks) != 0 {
+		var id uint32
+		var entry []byte
+		if id, entry, tracks, err = nextChild(tracks); err != nil {
+			return 0, 0, err
+		}
+		if id != idTrackEntry {
+			continue
+		}
+		for len(entry) != 0 {
+			var data []byte
+			if id, data, entry, err = nextChild(entry); err != nil {
+				return 0, 0, err
+			}
+			if id != idTrackNumber && id != idTrackUID {
+				continue
+			}
+			v, err := readUint(data)
+			if err != nil {
+				return 0, 0, err
+			}
+			if id == idTrackNumber {
+				number = max(number, v)
+			} else {
+				uid = max(uid, v)
+			}
+		}
+	}
+	return number + 1, uid + 1, nil
+}
+
+// timestampScale returns the timestamp scale in the data of an info
+// element.
+func timestampScale(info []byte) (uint64, error) {
+	v, err := findUint(info, idTimestampScale)
+	if err == errNotFound {
+		return defaultTimestampScale, nil
+	}
+	if err == nil && v == 0 {
+		err = errors.New("zero timestamp scale")
+	}
+	return v, err
+}
+
+// clusterTimestamp returns the timestamp in the data of a cluster.
+func clusterTimestamp(cluster []byte) (uint64, error) {
+	return findUint(cluster, idTimestamp)
+}
+
+var errNotFound = errors.New("element not found")
+
+// findUint returns the value of the first unsigned integer child with
+// the ID in the data of a master element.
+func findUint(data []byte, id uint32) (uint64, error) {
+	for len(data) != 0 {
+		child, payload, rest, err := nextChild(data)
+		if err != nil {
+			return 0, err
+		}
+		if child == id {
+			return readUint(payload)
+		}
+		data = rest
+	}
+	return 0, errNotFound
+}
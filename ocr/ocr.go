@@ -0,0 +1,66 @@
+// Package ocr converts image-based PGS subtitles to text subtitle
+// formats using optical character recognition.
+package ocr
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Engine recognizes text in subtitle images.
+type Engine interface {
+	Recognize(img image.Image) (string, error)
+}
+
+// Cue is a span of time during which text is shown.
+type Cue struct {
+	pgs.Interval
+	Text string
+}
+
+// Cues reads the stream, renders each shown display set, and recognizes
+// its text.
+func Cues(r *pgs.Reader, e Engine) ([]Cue, error) {
+	intervals, err := pgs.Intervals(r)
+	if err != nil {
+		return nil, err
+	}
+	cues := make([]Cue, len(intervals))
+	for i, iv := range intervals {
+		text, err := recognize(iv.DisplaySet, e)
+		if err != nil {
+			return nil, fmt.Errorf("display set at %s: %w", iv.Start, err)
+		}
+		cues[i] = Cue{iv, text}
+	}
+	return cues, nil
+}
+
+// FastCues returns the cues with a reading speed over maxCPS characters
+// per second, such as 25, which are shown too briefly to be read.
+func FastCues(cues []Cue, maxCPS float64) []Cue {
+	var fast []Cue
+	for _, c := range cues {
+		if c.CharsPerSecond(c.Text) > maxCPS {
+			fast = append(fast, c)
+		}
+	}
+	return fast
+}
+
+func recognize(ds *pgs.DisplaySet, e Engine) (string, error) {
+	img, err := ds.Render()
+	if err != nil {
+		return "", err
+	}
+	bounds, err := ds.ContentBounds()
+	if err != nil {
+		return "", err
+	}
+	if bounds.Empty() {
+		return "", nil
+	}
+	return e.Recognize(img.SubImage(bounds))
+}
@@ -0,0 +1,72 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+// sizeEngine recognizes the size of each image as its text.
+type sizeEngine struct{}
+
+func (sizeEngine) Recognize(img image.Image) (string, error) {
+	return fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()), nil
+}
+
+func TestToSRT(t *testing.T) {
+	sup := pgstest.BuildStream(pgstest.Subtitles(2), pgstest.ObjectSize(40, 10))
+	var b bytes.Buffer
+	if err := ToSRT(pgs.NewReader(bytes.NewReader(sup)), sizeEngine{}, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:01,000 --> 00:00:03,000\n40x10\n\n" +
+		"2\n00:00:04,000 --> 00:00:06,000\n40x10\n\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestToWebVTT(t *testing.T) {
+	sup := pgstest.BuildStream(pgstest.Subtitles(2), pgstest.ObjectSize(40, 10))
+	var b bytes.Buffer
+	if err := ToWebVTT(pgs.NewReader(bytes.NewReader(sup)), sizeEngine{}, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:01.000 --> 00:00:03.000\n40x10\n\n" +
+		"00:00:04.000 --> 00:00:06.000\n40x10\n\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestToTTML(t *testing.T) {
+	// The second subtitle shows the object of the first again
+	sup := pgstest.BuildStream(pgstest.Subtitles(2), pgstest.ReuseObjects(), pgstest.ObjectSize(40, 10))
+	var b bytes.Buffer
+	if err := ToTTML(pgs.NewReader(bytes.NewReader(sup)), sizeEngine{}, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling">
+  <head>
+    <layout>
+      <region xml:id="r0" tts:origin="48.96% 94.07%" tts:extent="2.08% 0.93%"></region>
+    </layout>
+  </head>
+  <body>
+    <div>
+      <p begin="00:00:01.000" end="00:00:03.000" region="r0">40x10</p>
+      <p begin="00:00:04.000" end="00:00:06.000" region="r0">40x10</p>
+    </div>
+  </body>
+</tt>
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package ocr
+
+import (
+	"image"
+	"image/draw"
+	"strings"
+)
+
+// TextRows returns the bounds of the horizontal bands of content in img,
+// from top to bottom, such as the lines of a multi-line subtitle. Bands
+// are separated by at least minGap rows of fully transparent pixels;
+// smaller gaps, like those between the parts of a letter, are merged
+// into the surrounding band. Each rectangle is trimmed to its content.
+func TextRows(img *image.RGBA, minGap int) []image.Rectangle {
+	if minGap < 1 {
+		minGap = 1
+	}
+	b := img.Bounds()
+	var rows []image.Rectangle
+	var cur image.Rectangle
+	gap := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		minX, maxX := b.Max.X, b.Min.X
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] != 0 {
+				if x < minX {
+					minX = x
+				}
+				maxX = x + 1
+			}
+		}
+		if minX >= maxX {
+			gap++
+			continue
+		}
+		line := image.Rect(minX, y, maxX, y+1)
+		if !cur.Empty() && gap < minGap {
+			cur = cur.Union(line)
+		} else {
+			if !cur.Empty() {
+				rows = append(rows, cur)
+			}
+			cur = line
+		}
+		gap = 0
+	}
+	if !cur.Empty() {
+		rows = append(rows, cur)
+	}
+	return rows
+}
+
+type lineEngine struct {
+	e      Engine
+	minGap int
+}
+
+// LineEngine returns an Engine that splits images into rows of text with
+// TextRows, recognizes each row separately with e, and joins the results
+// with newlines. Engines often merge the lines of multi-line subtitles,
+// which this avoids.
+func LineEngine(e Engine, minGap int) Engine {
+	return &lineEngine{e, minGap}
+}
+
+func (le *lineEngine) Recognize(img image.Image) (string, error) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Rect, img, rgba.Rect.Min, draw.Src)
+	}
+	var lines []string
+	for _, r := range TextRows(rgba, le.minGap) {
+		text, err := le.e.Recognize(rgba.SubImage(r))
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
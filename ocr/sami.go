@@ -0,0 +1,60 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+const samiHeader = `<SAMI>
+<HEAD>
+<STYLE TYPE="text/css"><!--
+P { font-family: Arial; text-align: center; }
+.SUBTITLE { Name: Subtitles; lang: und; }
+--></STYLE>
+</HEAD>
+<BODY>
+`
+
+// ToSAMI reads the stream and writes a Synchronized Accessible Media
+// Interchange document, for legacy Windows players, with a sync point of
+// recognized text at the start of each shown display set, in
+// milliseconds. Per SAMI convention, a sync point with a non-breaking
+// space clears the text at the end of each cue not immediately followed
+// by another.
+func ToSAMI(r *pgs.Reader, e Engine, w io.Writer) error {
+	cues, err := Cues(r, e)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(samiHeader)
+	for i, c := range cues {
+		fmt.Fprintf(bw, "<SYNC Start=%d><P Class=SUBTITLE>%s\n", c.Start.Milliseconds(), samiText(c.Text))
+		if i+1 == len(cues) || cues[i+1].Start.Milliseconds() != c.End.Milliseconds() {
+			fmt.Fprintf(bw, "<SYNC Start=%d><P Class=SUBTITLE>&nbsp;\n", c.End.Milliseconds())
+		}
+	}
+	bw.WriteString("</BODY>\n</SAMI>\n")
+	return bw.Flush()
+}
+
+// samiText escapes text and separates lines with line breaks.
+func samiText(text string) string {
+	var b bytes.Buffer
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if i != 0 {
+			b.WriteString("<BR>")
+		}
+		xml.EscapeText(&b, []byte(line))
+	}
+	if b.Len() == 0 {
+		return "&nbsp;"
+	}
+	return b.String()
+}
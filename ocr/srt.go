@@ -0,0 +1,80 @@
+package ocr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// ToSRT reads the stream and writes a SubRip document with a numbered
+// cue of recognized text for each shown display set. Display sets
+// without recognized text are omitted.
+func ToSRT(r *pgs.Reader, e Engine, w io.Writer) error {
+	cues, err := Cues(r, e)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	n := 0
+	for _, c := range cues {
+		text := cueText(c.Text, nil)
+		if text == "" {
+			continue
+		}
+		n++
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", n, srtTime(c.Start, ','), srtTime(c.End, ','), text)
+	}
+	return bw.Flush()
+}
+
+// ToWebVTT reads the stream and writes a WebVTT document with a cue of
+// recognized text for each shown display set. Cues of display sets in
+// the top third of the frame are placed at the top. Display sets without
+// recognized text are omitted.
+func ToWebVTT(r *pgs.Reader, e Engine, w io.Writer) error {
+	cues, err := Cues(r, e)
+	if err != nil {
+		return err
+	}
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		text := cueText(c.Text, escape)
+		if text == "" {
+			continue
+		}
+		var settings string
+		if c.DisplaySet.VerticalPlacement() == pgs.Top {
+			settings = " line:0"
+		}
+		fmt.Fprintf(bw, "%s --> %s%s\n%s\n\n", srtTime(c.Start, '.'), srtTime(c.End, '.'), settings, text)
+	}
+	return bw.Flush()
+}
+
+// cueText trims the lines of text, dropping blank lines, which would end
+// the cue, and escapes them, when escape is not nil.
+func cueText(text string, escape *strings.Replacer) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if escape != nil {
+				line = escape.Replace(line)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// srtTime formats a duration as a clock time with millisecond precision
+// after sep, which is a comma in SubRip and a period in WebVTT.
+func srtTime(d time.Duration, sep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
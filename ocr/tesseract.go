@@ -0,0 +1,50 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os/exec"
+	"strings"
+)
+
+// Tesseract is an Engine that runs the Tesseract OCR command, which must
+// be installed separately.
+type Tesseract struct {
+	Path     string   // Path of the command; "tesseract" in PATH when empty
+	Language string   // Language of trained data, such as "eng"; the default when empty
+	Args     []string // Extra arguments, such as "--psm", "6"
+}
+
+// Recognize encodes the image as PNG and recognizes its text with
+// Tesseract. Subtitles are usually light text on a transparent
+// background, so the image is first flattened onto black, from which
+// Tesseract separates light text as it does dark text on white.
+func (t *Tesseract) Recognize(img image.Image) (string, error) {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Rect, image.Black, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Rect, img, img.Bounds().Min, draw.Over)
+	var in bytes.Buffer
+	if err := png.Encode(&in, flat); err != nil {
+		return "", err
+	}
+	path := t.Path
+	if path == "" {
+		path = "tesseract"
+	}
+	args := []string{"stdin", "stdout"}
+	if t.Language != "" {
+		args = append(args, "-l", t.Language)
+	}
+	cmd := exec.Command(path, append(args, t.Args...)...)
+	cmd.Stdin = &in
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,115 @@
+package ocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+type ttmlDoc struct {
+	XMLName    xml.Name     `xml:"tt"`
+	Xmlns      string       `xml:"xmlns,attr"`
+	XmlnsTTS   string       `xml:"xmlns:tts,attr"`
+	Regions    []ttmlRegion `xml:"head>layout>region"`
+	Paragraphs []ttmlP      `xml:"body>div>p"`
+}
+
+type ttmlRegion struct {
+	ID     string `xml:"xml:id,attr"`
+	Origin string `xml:"tts:origin,attr"`
+	Extent string `xml:"tts:extent,attr"`
+}
+
+type ttmlP struct {
+	Begin  string `xml:"begin,attr"`
+	End    string `xml:"end,attr"`
+	Region string `xml:"region,attr,omitempty"`
+	Text   string `xml:",innerxml"`
+}
+
+// ToTTML reads the stream and writes a Timed Text Markup Language
+// document with a paragraph of recognized text for each shown display
+// set. Paragraphs are placed in a region covering the windows of the
+// display set, when defined. PGS subtitles are images, so the text is
+// only as good as the OCR engine and all styling is lost.
+func ToTTML(r *pgs.Reader, e Engine, w io.Writer) error {
+	cues, err := Cues(r, e)
+	if err != nil {
+		return err
+	}
+	doc := ttmlDoc{
+		Xmlns:    "http://www.w3.org/ns/ttml",
+		XmlnsTTS: "http://www.w3.org/ns/ttml#styling",
+	}
+	regions := make(map[ttmlRegion]string)
+	for _, c := range cues {
+		p := ttmlP{
+			Begin: ttmlTime(c.Start),
+			End:   ttmlTime(c.End),
+			Text:  ttmlText(c.Text),
+		}
+		if reg, ok := ttmlWindowRegion(c.DisplaySet); ok {
+			id, ok := regions[reg]
+			if !ok {
+				id = fmt.Sprintf("r%d", len(regions))
+				regions[reg] = id
+				reg.ID = id
+				doc.Regions = append(doc.Regions, reg)
+			}
+			p.Region = id
+		}
+		doc.Paragraphs = append(doc.Paragraphs, p)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// ttmlWindowRegion returns a region covering the windows of the display
+// set, in percentages of the video frame.
+func ttmlWindowRegion(ds *pgs.DisplaySet) (ttmlRegion, bool) {
+	if len(ds.Windows) == 0 || ds.Width == 0 || ds.Height == 0 {
+		return ttmlRegion{}, false
+	}
+	var r image.Rectangle
+	for _, w := range ds.Windows {
+		r = r.Union(w.Rect())
+	}
+	fw, fh := float64(ds.Width)/100, float64(ds.Height)/100
+	return ttmlRegion{
+		Origin: fmt.Sprintf("%.2f%% %.2f%%", float64(r.Min.X)/fw, float64(r.Min.Y)/fh),
+		Extent: fmt.Sprintf("%.2f%% %.2f%%", float64(r.Dx())/fw, float64(r.Dy())/fh),
+	}, true
+}
+
+// ttmlText escapes text and separates lines with line breaks.
+func ttmlText(text string) string {
+	var b bytes.Buffer
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if i != 0 {
+			b.WriteString("<br/>")
+		}
+		xml.EscapeText(&b, []byte(line))
+	}
+	return b.String()
+}
+
+// ttmlTime formats a duration as a clock time with millisecond
+// precision.
+func ttmlTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
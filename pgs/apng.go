@@ -0,0 +1,190 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+// MaxAPNGFrames is the most frames written by ExportAPNG, to bound the
+// size of previews of long tracks.
+const MaxAPNGFrames = 10000
+
+type apngFrame struct {
+	ds       *DisplaySet // Display set shown, or nil when clear
+	duration time.Duration
+}
+
+// ExportAPNG reads the stream and writes an animated PNG showing each
+// subtitle for its interval between start and end, with fully
+// transparent frames while nothing is shown, as a preview that plays in
+// browsers. An end of 0 continues to the end of the stream. Frames have
+// the video dimensions of the first subtitle in the range. It fails if
+// more than MaxAPNGFrames frames would be written, so narrow the range
+// for long tracks.
+func ExportAPNG(r *Reader, w io.Writer, start, end time.Duration) error {
+	var frames []apngFrame
+	var size image.Rectangle
+	t := start
+	for iv, err := range r.Intervals() {
+		if err != nil {
+			return err
+		}
+		if end > 0 && iv.Start >= end {
+			break
+		}
+		s, e := iv.Start, iv.End
+		if s < t {
+			s = t
+		}
+		if end > 0 && e > end {
+			e = end
+		}
+		if e <= s {
+			continue
+		}
+		if size.Empty() {
+			size = image.Rect(0, 0, int(iv.DisplaySet.Width), int(iv.DisplaySet.Height))
+		}
+		if s > t {
+			frames = append(frames, apngFrame{nil, s - t})
+		}
+		frames = append(frames, apngFrame{iv.DisplaySet, e - s})
+		if len(frames) > MaxAPNGFrames {
+			return fmt.Errorf("more than %d frames", MaxAPNGFrames)
+		}
+		t = e
+	}
+	if end > t && len(frames) != 0 {
+		frames = append(frames, apngFrame{nil, end - t})
+	}
+	if len(frames) == 0 || size.Empty() {
+		return errors.New("no subtitles in range")
+	}
+
+	aw := &apngWriter{w: w}
+	var clear []byte // Encoded transparent frame, reused
+	for i, f := range frames {
+		var data []byte
+		if f.ds == nil && clear != nil {
+			data = clear
+		} else {
+			canvas := image.NewRGBA(size)
+			if f.ds != nil {
+				img, err := f.ds.Render()
+				if err != nil {
+					return fmt.Errorf("display set at %s: %w", f.ds.PresentationTime, err)
+				}
+				draw.Draw(canvas, size, img, image.Point{}, draw.Src)
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, canvas); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+			if f.ds == nil {
+				clear = data
+			}
+		}
+		if err := aw.writeFrame(data, i, len(frames), f.duration); err != nil {
+			return err
+		}
+	}
+	return aw.writeChunk("IEND", nil)
+}
+
+// apngWriter assembles an animated PNG from PNG encoded frames of the
+// same dimensions.
+type apngWriter struct {
+	w    io.Writer
+	seq  uint32 // Sequence number of the next frame chunk
+	ihdr []byte // Header of the first frame
+}
+
+func (aw *apngWriter) writeFrame(data []byte, i, n int, duration time.Duration) error {
+	var ihdr []byte
+	var idat [][]byte
+	for p := 8; p+12 <= len(data); {
+		l := int(binary.BigEndian.Uint32(data[p:]))
+		typ, body := string(data[p+4:p+8]), data[p+8:p+8+l]
+		switch typ {
+		case "IHDR":
+			ihdr = body
+		case "IDAT":
+			idat = append(idat, body)
+		}
+		p += 12 + l
+	}
+	if aw.ihdr != nil && !bytes.Equal(ihdr, aw.ihdr) {
+		// The encoder drops alpha from fully opaque images
+		return fmt.Errorf("frame %d: encoded with different header", i)
+	}
+	if i == 0 {
+		aw.ihdr = ihdr
+		if _, err := aw.w.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+			return err
+		}
+		if err := aw.writeChunk("IHDR", ihdr); err != nil {
+			return err
+		}
+		actl := make([]byte, 8)
+		binary.BigEndian.PutUint32(actl, uint32(n)) // Loop forever
+		if err := aw.writeChunk("acTL", actl); err != nil {
+			return err
+		}
+	}
+
+	// Delays are a fraction of a second with 16-bit terms
+	num, den := duration.Milliseconds(), int64(1000)
+	if num > 0xffff {
+		num, den = int64(duration/(10*time.Millisecond)), 100
+	}
+	if num > 0xffff {
+		num, den = int64(duration/time.Second), 1
+	}
+	if num > 0xffff {
+		num = 0xffff
+	}
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], aw.seq)
+	copy(fctl[4:12], ihdr[0:8]) // Width and height
+	binary.BigEndian.PutUint16(fctl[20:], uint16(num))
+	binary.BigEndian.PutUint16(fctl[22:], uint16(den))
+	aw.seq++
+	if err := aw.writeChunk("fcTL", fctl); err != nil {
+		return err
+	}
+	for _, d := range idat {
+		if i == 0 {
+			if err := aw.writeChunk("IDAT", d); err != nil {
+				return err
+			}
+			continue
+		}
+		fdat := make([]byte, 4+len(d))
+		binary.BigEndian.PutUint32(fdat, aw.seq)
+		copy(fdat[4:], d)
+		aw.seq++
+		if err := aw.writeChunk("fdAT", fdat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (aw *apngWriter) writeChunk(typ string, data []byte) error {
+	b := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], typ)
+	b = append(b, data...)
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+	_, err := aw.w.Write(b)
+	return err
+}
@@ -0,0 +1,83 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func BenchmarkReadPalette(b *testing.B) {
+	pds := []byte{0, 0} // Palette ID and version
+	for i := 0; i < 256; i++ {
+		pds = append(pds, uint8(i), 16, 128, 128, 255)
+	}
+	br := bytes.NewReader(pds)
+	r := NewReader(br)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		br.Reset(pds)
+		if _, err := r.readPalette(uint16(len(pds))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkStream(b *testing.B) []byte {
+	img := image.NewPaletted(image.Rect(0, 0, 400, 60), color.Palette{color.Transparent, color.White})
+	for y := 10; y < 50; y++ {
+		for x := 10; x < 390; x += 3 {
+			img.SetColorIndex(x, y, 1)
+		}
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < 100; i++ {
+		ds, err := NewDisplaySet(img, 0, 0, time.Duration(2*i)*time.Second)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ds.Width, ds.Height = 1920, 1080
+		clear := NewClearDisplaySet(time.Duration(2*i+1)*time.Second, 0)
+		clear.Width, clear.Height = 1920, 1080
+		if err := w.Write(ds); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Write(clear); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkScanHeaders(b *testing.B) {
+	stream := benchmarkStream(b)
+	br := bytes.NewReader(stream)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br.Reset(stream)
+		err := ScanHeaders(br, func(SegmentType, time.Duration, time.Duration, uint16, int64) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	stream := benchmarkStream(b)
+	br := bytes.NewReader(stream)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br.Reset(stream)
+		if _, err := NewReader(br).ReadAll(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+)
+
+// NewDisplaySet returns a display set that shows img with its top left
+// pixel at (x, y) in the frame at t. It starts an epoch with a window
+// sized to the image, a palette of the colors of the image, and the run
+// length encoded object. The video dimensions, frame rate, and
+// composition number are left for the caller to set to those of the
+// stream.
+func NewDisplaySet(img *image.Paletted, x, y int, t time.Duration) (*DisplaySet, error) {
+	if len(img.Palette) > 255 {
+		return nil, fmt.Errorf("palette of %d colors exceeds 255 entries", len(img.Palette))
+	}
+	if img.Rect.Empty() {
+		return nil, errors.New("empty image")
+	}
+	dx, dy := img.Rect.Dx(), img.Rect.Dy()
+	if x < 0 || y < 0 || x+dx > 0xffff || y+dy > 0xffff {
+		return nil, fmt.Errorf("position (%d, %d) out of range for %dx%d image", x, y, dx, dy)
+	}
+	rle, err := EncodeRLE(img)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]PaletteEntry, len(img.Palette))
+	for i, c := range img.Palette {
+		entries[i] = PaletteEntry{ID: uint8(i), NYCbCrA: color.NYCbCrAModel.Convert(c).(color.NYCbCrA)}
+	}
+	return &DisplaySet{
+		PresentationTime: t,
+		DecodingTime:     t,
+		PresentationComposition: PresentationComposition{
+			CompositionState: EpochStart,
+			Objects:          []CompositionObject{{X: uint16(x), Y: uint16(y)}},
+		},
+		Windows: []Window{{X: uint16(x), Y: uint16(y), Width: uint16(dx), Height: uint16(dy)}},
+		Palette: &Palette{Entries: entries},
+		Object: &Object{
+			First:   true,
+			Last:    true,
+			DataLen: len(rle.Data),
+			Image:   *rle,
+		},
+	}, nil
+}
+
+// NewClearDisplaySet returns a display set that clears the screen at t,
+// a Normal composition without composition objects. The video
+// dimensions, frame rate, and composition number are left for the caller
+// to set to those of the stream.
+func NewClearDisplaySet(t time.Duration, paletteID uint8) *DisplaySet {
+	return &DisplaySet{
+		PresentationTime: t,
+		DecodingTime:     t,
+		PresentationComposition: PresentationComposition{
+			CompositionState: Normal,
+			PaletteID:        paletteID,
+		},
+	}
+}
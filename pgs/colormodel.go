@@ -0,0 +1,109 @@
+package pgs
+
+import (
+	"fmt"
+	"io"
+)
+
+// ColorModel is the matrix used to encode RGB colors as YCbCr.
+type ColorModel uint8
+
+const (
+	BT601 ColorModel = iota // Standard definition
+	BT709                   // High definition
+)
+
+func (m ColorModel) String() string {
+	switch m {
+	case BT601:
+		return "BT.601"
+	case BT709:
+		return "BT.709"
+	}
+	return fmt.Sprintf("ColorModel(%d)", uint8(m))
+}
+
+// coefficients returns the red and blue luma coefficients of the matrix.
+func (m ColorModel) coefficients() (kr, kb float64) {
+	if m == BT709 {
+		return 0.2126, 0.0722
+	}
+	return 0.299, 0.114
+}
+
+// GuessColorModel reads the stream and guesses the matrix its palettes
+// were encoded with, since streams do not record it, returning a
+// confidence from 0.5 to 1 in the guess. It scores the frame size, with
+// HD frames of at least 1280x720 almost always BT.709 and smaller SD
+// frames BT.601, weighted 0.8, and the palettes, weighted 0.2, by which
+// matrix decodes the visible entries with less clipping outside of RGB;
+// neutral colors, such as white text, give no evidence either way. The
+// confidence is 0.5 plus half the magnitude of the score, so it is 0.9
+// for a frame size with no evidence from the palettes. Without a frame
+// size, the palettes alone decide.
+func GuessColorModel(r *Reader) (ColorModel, float64, error) {
+	defer r.SkipObjectData(r.skipData)
+	r.SkipObjectData(true)
+	var dims float64 // +1 for HD, -1 for SD
+	var clip601, clip709 float64
+	seen := make(map[PaletteEntry]bool)
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return BT601, 0, err
+		}
+		if dims == 0 && ds.Width != 0 && ds.Height != 0 {
+			dims = -1
+			if ds.Width >= 1280 || ds.Height >= 720 {
+				dims = 1
+			}
+		}
+		if ds.Palette == nil {
+			continue
+		}
+		for _, e := range ds.Palette.Entries {
+			e.ID = 0
+			if e.A == 0 || seen[e] {
+				continue
+			}
+			seen[e] = true
+			clip601 += clipping(e, BT601)
+			clip709 += clipping(e, BT709)
+		}
+	}
+	var evidence float64 // +1 favors BT.709
+	if clip601+clip709 != 0 {
+		evidence = (clip601 - clip709) / (clip601 + clip709)
+	}
+	score := evidence
+	if dims != 0 {
+		score = 0.8*dims + 0.2*evidence
+	}
+	if score > 0 {
+		return BT709, 0.5 + score/2, nil
+	}
+	return BT601, 0.5 - score/2, nil
+}
+
+// clipping returns the total amount by which the components of the entry
+// fall outside of 0 to 255 when decoded as full range YCbCr with the
+// matrix.
+func clipping(e PaletteEntry, m ColorModel) float64 {
+	kr, kb := m.coefficients()
+	y, cb, cr := float64(e.Y), float64(e.Cb)-128, float64(e.Cr)-128
+	r := y + 2*(1-kr)*cr
+	b := y + 2*(1-kb)*cb
+	g := (y - kr*r - kb*b) / (1 - kr - kb)
+	var clip float64
+	for _, v := range [...]float64{r, g, b} {
+		if v < 0 {
+			clip -= v
+		} else if v > 255 {
+			clip += v - 255
+		}
+	}
+	return clip
+}
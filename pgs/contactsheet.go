@@ -0,0 +1,112 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+)
+
+// ContactSheet reads the stream and tiles a thumbnail of each shown
+// display set into a grid with cols columns, each labeled with its
+// presentation time. Thumbnails are scaled from the whole video frame to
+// thumbWidth pixels wide, keeping the aspect ratio of the first frame.
+// Display sets are rendered as in Render, with objects defined earlier in
+// the epoch.
+func ContactSheet(r *Reader, cols, thumbWidth int) (*image.RGBA, error) {
+	if cols <= 0 || thumbWidth <= 0 {
+		return nil, errors.New("columns and thumbnail width must be positive")
+	}
+	type thumb struct {
+		img *image.RGBA
+		t   time.Duration
+	}
+	var thumbs []thumb
+	thumbHeight := 0
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.IsClear() {
+			continue
+		}
+		if ds.Width == 0 || ds.Height == 0 {
+			return nil, fmt.Errorf("display set %d: invalid video dimensions: %dx%d", i, ds.Width, ds.Height)
+		}
+		if thumbHeight == 0 {
+			thumbHeight = (thumbWidth*int(ds.Height) + int(ds.Width)/2) / int(ds.Width)
+			if thumbHeight == 0 {
+				thumbHeight = 1
+			}
+		}
+		img, err := ds.Render()
+		if err != nil {
+			return nil, fmt.Errorf("display set %d: %w", i, err)
+		}
+		thumbs = append(thumbs, thumb{scaleRGBA(img, thumbWidth, thumbHeight), ds.PresentationTime})
+	}
+
+	const pad = 2
+	tileW, tileH := thumbWidth+2*pad, thumbHeight+3*pad+glyphHeight
+	rows := (len(thumbs) + cols - 1) / cols
+	if len(thumbs) < cols {
+		cols = len(thumbs)
+	}
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*tileW, rows*tileH))
+	draw.Draw(sheet, sheet.Rect, image.NewUniform(color.Gray{0x20}), image.Point{}, draw.Src)
+	for i, th := range thumbs {
+		min := image.Point{i%cols*tileW + pad, i/cols*tileH + pad}
+		draw.Draw(sheet, image.Rectangle{min, min.Add(th.img.Rect.Size())}, th.img, image.Point{}, draw.Over)
+		drawLabel(sheet, image.Point{min.X, min.Y + thumbHeight + pad}, formatLabel(th.t), color.White)
+	}
+	return sheet, nil
+}
+
+func formatLabel(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+const glyphWidth, glyphHeight = 3, 5
+
+// glyphs is a 3×5 pixel font for timestamps and labels, with each row of a glyph
+// stored in the low three bits.
+var glyphs = map[rune][glyphHeight]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	':': {0, 2, 0, 2, 0},
+	'.': {0, 0, 0, 0, 2},
+	'C': {7, 4, 4, 4, 7},
+	'O': {2, 5, 5, 5, 2},
+	'W': {5, 5, 5, 7, 5},
+}
+
+// drawLabel draws text in color c at pt, clipped to the image.
+func drawLabel(img *image.RGBA, pt image.Point, text string, c color.Color) {
+	for _, ch := range text {
+		g := glyphs[ch]
+		for y, row := range g {
+			for x := 0; x < glyphWidth; x++ {
+				if row&(1<<(glyphWidth-1-x)) != 0 {
+					img.Set(pt.X+x, pt.Y+y, c)
+				}
+			}
+		}
+		pt.X += glyphWidth + 1
+	}
+}
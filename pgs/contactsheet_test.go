@@ -0,0 +1,30 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestContactSheet(t *testing.T) {
+	// The second thumbnail shows objects defined by the first display set
+	sheet, err := ContactSheet(NewReader(bytes.NewReader(reusedObjectStream(t))), 2, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two 8x4 thumbnails, each padded and labeled
+	if sheet.Rect != image.Rect(0, 0, 2*12, 15) {
+		t.Fatalf("sheet bounds %v, want 24x15", sheet.Rect)
+	}
+	bg := color.RGBA{0x20, 0x20, 0x20, 0xff}
+	for i, pt := range []image.Point{{2, 2}, {14, 2}} {
+		if got := sheet.RGBAAt(pt.X, pt.Y); got == bg {
+			t.Errorf("thumbnail %d not drawn", i)
+		}
+	}
+	// The shade blended on the second thumbnail only
+	if a, b := sheet.RGBAAt(2+3, 2), sheet.RGBAAt(14+3, 2); a == b {
+		t.Errorf("thumbnails do not differ: %v", a)
+	}
+}
@@ -0,0 +1,79 @@
+package pgs
+
+import (
+	"image"
+	"time"
+)
+
+// Decoder converts images with options for recovering from malformed
+// data. The zero value decodes the same as Image.Convert. A Decoder
+// collecting metrics must not be used concurrently.
+type Decoder struct {
+	// SwapDimensions retries decoding with the width and height swapped
+	// when the data is inconsistent with the declared dimensions, as
+	// written by some buggy encoders. When the swapped dimensions decode
+	// cleanly, the returned image has the swapped dimensions, so callers
+	// can detect the recovery by comparing its bounds.
+	SwapDimensions bool
+
+	// Lenient applies the most common interpretation of ambiguous run
+	// length encoded data, rather than failing: each line ends at its end
+	// of line marker, even when the line has the wrong width; pixels
+	// beyond the width or height are dropped; and a missing final end of
+	// line marker or missing lines, which are left transparent, are
+	// accepted. The default strict decoding rejects any such data, for
+	// detecting nonconformant encoders.
+	Lenient bool
+
+	// CollectMetrics records the work done by each conversion, which is
+	// reported by Metrics. It is off by default to keep the overhead out
+	// of decoding.
+	CollectMetrics bool
+
+	metrics DecodeMetrics
+}
+
+// DecodeMetrics is the work done by a Decoder collecting metrics.
+type DecodeMetrics struct {
+	Objects int           // Images decoded successfully
+	Pixels  int64         // Pixels of the images decoded successfully
+	Runs    int64         // Runs decoded, including by failed conversions
+	Time    time.Duration // Wall time spent in conversions
+}
+
+func (dec *Decoder) Convert(img *Image, p *Palette) (*image.Paletted, error) {
+	if !dec.CollectMetrics {
+		return dec.convert(img, p, nil)
+	}
+	start := time.Now()
+	pimg, err := dec.convert(img, p, &dec.metrics.Runs)
+	dec.metrics.Time += time.Since(start)
+	if err == nil {
+		dec.metrics.Objects++
+		dec.metrics.Pixels += int64(pimg.Rect.Dx()) * int64(pimg.Rect.Dy())
+	}
+	return pimg, err
+}
+
+func (dec *Decoder) convert(img *Image, p *Palette, runs *int64) (*image.Paletted, error) {
+	pimg, err := img.convert(p, dec.Lenient, runs)
+	if err != nil && dec.SwapDimensions && img.Width != img.Height {
+		swapped := *img
+		swapped.Width, swapped.Height = img.Height, img.Width
+		if pimg, err := swapped.convert(p, dec.Lenient, runs); err == nil {
+			return pimg, nil
+		}
+	}
+	return pimg, err
+}
+
+// Metrics returns the totals of the metrics collected since the Decoder
+// was created or the metrics were reset.
+func (dec *Decoder) Metrics() DecodeMetrics {
+	return dec.metrics
+}
+
+// ResetMetrics sets the collected metrics to zero.
+func (dec *Decoder) ResetMetrics() {
+	dec.metrics = DecodeMetrics{}
+}
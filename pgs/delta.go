@@ -0,0 +1,382 @@
+package pgs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The delta format begins with deltaMagic and is followed by operations,
+// each an opcode byte and its operands in big endian, applied in order
+// to the display sets of the base stream:
+//
+//	'C' n uint32                copy the next n display sets
+//	'D' n uint32                drop the next n display sets
+//	'M' pts, dts int64 p uint8  copy the next display set with new times,
+//	    [palette]               and with a new palette when p is 1
+//	'I' pts, dts int64          insert a display set encoded as segments
+//	    len uint32 segments
+//
+// A palette is its ID and version, a uint16 entry count, and the entries
+// as ID, Y, Cb, Cr, and alpha. Times are durations in nanoseconds.
+const deltaMagic = "PGSDELTA1"
+
+const (
+	deltaCopy   = 'C'
+	deltaDrop   = 'D'
+	deltaModify = 'M'
+	deltaInsert = 'I'
+)
+
+// DeltaEncode writes a delta that transforms the display sets of base
+// into those of modified, for compactly storing edited variants of a
+// track. Display sets are matched by a hash of their content, excluding
+// timing, using a longest common subsequence of the regions that differ.
+// Matched display sets with changed times, and display sets that differ
+// only in timing and palette from the base display set at the same
+// position among those unmatched, are stored as modifications; others
+// are stored in full. Both streams are held in memory.
+func DeltaEncode(base, modified *Reader, w io.Writer) error {
+	a, err := base.ReadAll()
+	if err != nil {
+		return fmt.Errorf("base: %w", err)
+	}
+	b, err := modified.ReadAll()
+	if err != nil {
+		return fmt.Errorf("modified: %w", err)
+	}
+	ha, err := contentHashes(a, true)
+	if err != nil {
+		return fmt.Errorf("base: %w", err)
+	}
+	hb, err := contentHashes(b, true)
+	if err != nil {
+		return fmt.Errorf("modified: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	d := &deltaWriter{w: bw}
+	d.w.WriteString(deltaMagic)
+	i, j := 0, 0
+	for _, m := range matchHashes(ha, hb) {
+		if err := d.unmatched(a[i:m[0]], b[j:m[1]]); err != nil {
+			return err
+		}
+		if sameTiming(&a[m[0]], &b[m[1]]) {
+			d.copy()
+		} else {
+			d.modify(&b[m[1]], nil)
+		}
+		i, j = m[0]+1, m[1]+1
+	}
+	if err := d.unmatched(a[i:], b[j:]); err != nil {
+		return err
+	}
+	d.flush()
+	if d.err != nil {
+		return d.err
+	}
+	return bw.Flush()
+}
+
+// ApplyDelta reads the display sets of base, applies the delta written
+// by DeltaEncode, and writes the resulting display sets to w.
+func ApplyDelta(base *Reader, delta io.Reader, w *Writer) error {
+	br := bufio.NewReader(delta)
+	magic := make([]byte, len(deltaMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != deltaMagic {
+		return errors.New("delta: bad magic")
+	}
+	next := func() (*DisplaySet, error) {
+		ds, err := base.Read()
+		if err == io.EOF {
+			return nil, errors.New("base ended before delta")
+		}
+		return ds, err
+	}
+	for op := 1; ; op++ {
+		code, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyDeltaOp(code, br, next, w); err != nil {
+			return fmt.Errorf("delta operation %d: %w", op, err)
+		}
+	}
+}
+
+func applyDeltaOp(code byte, r io.Reader, next func() (*DisplaySet, error), w *Writer) error {
+	switch code {
+	case deltaCopy, deltaDrop:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return err
+		}
+		for ; n > 0; n-- {
+			ds, err := next()
+			if err != nil {
+				return err
+			}
+			if code == deltaCopy {
+				if err := w.Write(ds); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case deltaModify:
+		ds, err := next()
+		if err != nil {
+			return err
+		}
+		var m struct {
+			PTS, DTS   time.Duration
+			HasPalette uint8
+		}
+		if err := binary.Read(r, binary.BigEndian, &m); err != nil {
+			return err
+		}
+		ds.PresentationTime, ds.DecodingTime = m.PTS, m.DTS
+		if m.HasPalette != 0 {
+			if ds.Palette, err = readDeltaPalette(r); err != nil {
+				return err
+			}
+		}
+		return w.Write(ds)
+	case deltaInsert:
+		var ins struct {
+			PTS, DTS time.Duration
+			Len      uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &ins); err != nil {
+			return err
+		}
+		data := make([]byte, ins.Len)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		ds, err := NewReader(bytes.NewReader(data)).Read()
+		if err != nil {
+			return err
+		}
+		ds.PresentationTime, ds.DecodingTime = ins.PTS, ins.DTS
+		return w.Write(ds)
+	}
+	return fmt.Errorf("unknown opcode 0x%02x", code)
+}
+
+// deltaWriter writes delta operations, coalescing runs of copies and
+// drops.
+type deltaWriter struct {
+	w       *bufio.Writer
+	pending byte
+	n       uint32
+	err     error
+}
+
+func (d *deltaWriter) run(code byte) {
+	if d.pending != code {
+		d.flush()
+		d.pending = code
+	}
+	d.n++
+}
+
+func (d *deltaWriter) copy() { d.run(deltaCopy) }
+func (d *deltaWriter) drop() { d.run(deltaDrop) }
+
+func (d *deltaWriter) flush() {
+	if d.n != 0 {
+		d.write(d.pending, d.n)
+	}
+	d.pending, d.n = 0, 0
+}
+
+func (d *deltaWriter) write(data ...any) {
+	for _, v := range data {
+		if d.err == nil {
+			d.err = binary.Write(d.w, binary.BigEndian, v)
+		}
+	}
+}
+
+func (d *deltaWriter) modify(ds *DisplaySet, p *Palette) {
+	d.flush()
+	var hasPalette uint8
+	if p != nil {
+		hasPalette = 1
+	}
+	d.write(byte(deltaModify), ds.PresentationTime, ds.DecodingTime, hasPalette)
+	if p != nil {
+		d.write(p.ID, p.Version, uint16(len(p.Entries)), p.Entries)
+	}
+}
+
+func (d *deltaWriter) insert(ds *DisplaySet) error {
+	d.flush()
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(ds); err != nil {
+		return err
+	}
+	d.write(byte(deltaInsert), ds.PresentationTime, ds.DecodingTime, uint32(buf.Len()), buf.Bytes())
+	return nil
+}
+
+// unmatched writes operations for a region of display sets, a of the
+// base and b of the modified stream, with no display sets in common.
+func (d *deltaWriter) unmatched(a, b []DisplaySet) error {
+	ha, err := contentHashes(a, false)
+	if err != nil {
+		return err
+	}
+	hb, err := contentHashes(b, false)
+	if err != nil {
+		return err
+	}
+	for k := range b {
+		if k < len(a) && ha[k] == hb[k] && b[k].Palette != nil {
+			d.modify(&b[k], b[k].Palette)
+			continue
+		}
+		if k < len(a) {
+			d.drop()
+		}
+		if err := d.insert(&b[k]); err != nil {
+			return err
+		}
+	}
+	for k := len(b); k < len(a); k++ {
+		d.drop()
+	}
+	return nil
+}
+
+func readDeltaPalette(r io.Reader) (*Palette, error) {
+	var h struct {
+		ID, Version uint8
+		Len         uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	p := &Palette{ID: h.ID, Version: h.Version, Entries: make([]PaletteEntry, h.Len)}
+	if err := binary.Read(r, binary.BigEndian, p.Entries); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func sameTiming(a, b *DisplaySet) bool {
+	return a.PresentationTime == b.PresentationTime && a.DecodingTime == b.DecodingTime
+}
+
+// contentHashes returns a hash of the encoded segments of each display
+// set, excluding timing and raw headers, and the palette unless
+// withPalette.
+func contentHashes(stream []DisplaySet, withPalette bool) ([][sha256.Size]byte, error) {
+	hashes := make([][sha256.Size]byte, len(stream))
+	for i, ds := range stream {
+		ds.PresentationTime, ds.DecodingTime = 0, 0
+		ds.Headers, ds.Payloads = nil, nil
+		if !withPalette {
+			ds.Palette = nil
+		}
+		h := sha256.New()
+		if err := NewWriter(h).Write(&ds); err != nil {
+			return nil, fmt.Errorf("display set %d: %w", i, err)
+		}
+		h.Sum(hashes[i][:0])
+	}
+	return hashes, nil
+}
+
+// matchHashes returns the index pairs of a longest common subsequence of
+// a and b. Common prefixes and suffixes are matched directly, so the cost
+// is quadratic only in the length of the region that differs.
+func matchHashes(a, b [][sha256.Size]byte) [][2]int {
+	var matches [][2]int
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		matches = append(matches, [2]int{pre, pre})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	// lcs[i][j] is the length of the LCS of ma[i:] and mb[j:]
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(ma) && j < len(mb); {
+		switch {
+		case ma[i] == mb[j]:
+			matches = append(matches, [2]int{pre + i, pre + j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	for k := suf; k > 0; k-- {
+		matches = append(matches, [2]int{len(a) - k, len(b) - k})
+	}
+	return matches
+}
+
+// StreamDiff counts the display sets that differ between two streams.
+type StreamDiff struct {
+	Modified int // Changed in content or timing
+	Dropped  int // Only in the first stream
+	Added    int // Only in the second stream
+}
+
+// CompareStreams matches the display sets of two streams as DeltaEncode
+// does and counts those that differ. Display sets of a and b that are
+// unmatched at the same position between matches count as modified.
+func CompareStreams(a, b []DisplaySet) (StreamDiff, error) {
+	ha, err := contentHashes(a, true)
+	if err != nil {
+		return StreamDiff{}, err
+	}
+	hb, err := contentHashes(b, true)
+	if err != nil {
+		return StreamDiff{}, err
+	}
+	var diff StreamDiff
+	unmatched := func(na, nb int) {
+		diff.Modified += min(na, nb)
+		diff.Dropped += max(na-nb, 0)
+		diff.Added += max(nb-na, 0)
+	}
+	i, j := 0, 0
+	for _, m := range matchHashes(ha, hb) {
+		unmatched(m[0]-i, m[1]-j)
+		if !sameTiming(&a[m[0]], &b[m[1]]) {
+			diff.Modified++
+		}
+		i, j = m[0]+1, m[1]+1
+	}
+	unmatched(len(a)-i, len(b)-j)
+	return diff, nil
+}
@@ -0,0 +1,114 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deltaStreams returns a base stream of n subtitles and a variant with
+// the second retimed, the third recolored, and a new subtitle in place
+// of the fourth.
+func deltaStreams(t *testing.T) (base, modified []DisplaySet) {
+	t.Helper()
+	subtitle := func(i int) DisplaySet {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Transparent, color.White})
+		img.Pix[i%len(img.Pix)] = 1
+		ds, err := NewDisplaySet(img, 0, 0, time.Duration(i+1)*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds.Width, ds.Height = 8, 8
+		ds.CompositionNumber = uint16(i)
+		return *ds
+	}
+	for i := range 4 {
+		base = append(base, subtitle(i))
+	}
+	modified = append(modified, base[:3]...)
+	modified[1].PresentationTime += 500 * time.Millisecond
+	modified[1].DecodingTime += 500 * time.Millisecond
+	recolored := *modified[2].Palette
+	recolored.Entries = append([]PaletteEntry(nil), recolored.Entries...)
+	recolored.Entries[1].NYCbCrA = color.NYCbCrA{YCbCr: color.YCbCr{Y: 81, Cb: 90, Cr: 240}, A: 0xff}
+	modified[2].Palette = &recolored
+	modified = append(modified, subtitle(7))
+	return base, modified
+}
+
+func encodeStream(t *testing.T, stream []DisplaySet) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	base, modified := deltaStreams(t)
+	supBase, supModified := encodeStream(t, base), encodeStream(t, modified)
+	var delta bytes.Buffer
+	if err := DeltaEncode(NewReader(bytes.NewReader(supBase)), NewReader(bytes.NewReader(supModified)), &delta); err != nil {
+		t.Fatal(err)
+	}
+	// The first subtitle is copied, the retimed and recolored ones stored
+	// as modifications, the last one dropped, and the new one inserted
+	var ops []byte
+	r := bytes.NewReader(delta.Bytes()[len(deltaMagic):])
+	br := NewReader(bytes.NewReader(supBase))
+	for r.Len() != 0 {
+		code, _ := r.ReadByte()
+		if err := applyDeltaOp(code, r, br.Read, NewWriter(io.Discard)); err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, code)
+	}
+	if string(ops) != "CMMDI" {
+		t.Errorf("delta operations %q, want CMMDI", ops)
+	}
+	var out bytes.Buffer
+	if err := ApplyDelta(NewReader(bytes.NewReader(supBase)), bytes.NewReader(delta.Bytes()), NewWriter(&out)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), supModified) {
+		t.Error("applied delta differs from modified stream")
+	}
+
+	// Identical streams give a delta copying everything
+	var same bytes.Buffer
+	if err := DeltaEncode(NewReader(bytes.NewReader(supBase)), NewReader(bytes.NewReader(supBase)), &same); err != nil {
+		t.Fatal(err)
+	}
+	if want := deltaMagic + "C\x00\x00\x00\x04"; same.String() != want {
+		t.Errorf("delta of identical streams is %q, want %q", same.String(), want)
+	}
+}
+
+func TestApplyDeltaCorrupt(t *testing.T) {
+	base, modified := deltaStreams(t)
+	supBase := encodeStream(t, base)
+	var delta bytes.Buffer
+	if err := DeltaEncode(NewReader(bytes.NewReader(supBase)), NewReader(bytes.NewReader(encodeStream(t, modified))), &delta); err != nil {
+		t.Fatal(err)
+	}
+	d := delta.Bytes()
+	for _, tc := range []struct {
+		name, delta, err string
+	}{
+		{"bad magic", "PGSDELTA0C\x00\x00\x00\x01", "bad magic"},
+		{"truncated", string(d[:len(d)-3]), "delta operation"},
+		{"truncated operand", deltaMagic + "C\x00\x00", "delta operation 1"},
+		{"unknown opcode", deltaMagic + "X", "unknown opcode 0x58"},
+		{"past base", deltaMagic + "C\x00\x00\x00\x05", "base ended before delta"},
+	} {
+		err := ApplyDelta(NewReader(bytes.NewReader(supBase)), strings.NewReader(tc.delta), NewWriter(&bytes.Buffer{}))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"time"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity uint8
+
+const (
+	// Warning is a departure from the conventions of BluRay encoders
+	// that decoders tolerate.
+	Warning Severity = iota
+	// Error is a violation of the format that decoders may reject or
+	// display incorrectly.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", uint8(s))
+}
+
+// Diagnostic is a problem found in a stream by Validate.
+type Diagnostic struct {
+	Severity   Severity
+	Offset     int64         // Byte offset in the stream
+	DisplaySet int           // Index of the display set, or -1 if not read
+	Segment    int           // Index of the segment in the display set, or -1
+	Time       time.Duration // Presentation time of the display set
+	Message    string
+}
+
+func (d Diagnostic) String() string {
+	loc := fmt.Sprintf("offset %d", d.Offset)
+	if d.DisplaySet >= 0 {
+		loc += fmt.Sprintf(", display set %d at %s", d.DisplaySet, d.Time)
+		if d.Segment >= 0 {
+			loc += fmt.Sprintf(", segment %d", d.Segment)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, d.Severity, d.Message)
+}
+
+// Validate reads the stream and reports its violations of the format as
+// diagnostics, continuing past damaged display sets as with
+// Reader.Resync and Reader.SkipUndecodableSegments, which are errors. In
+// each display set it checks windows, as in DisplaySet.ValidateWindows,
+// and for overlaps; that objects decode, as in Object.VerifyDecode; that
+// composition objects refer to windows, objects, and palettes defined in
+// the epoch and lie in the frame and their windows; and that times and
+// composition numbers increase. Epochs not ending with a clear are
+// warned. The error is only for failures to read from r.
+func Validate(r io.Reader) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	pr := NewReader(r)
+	pr.Resync(true)
+	pr.SkipUndecodableSegments(true)
+	pr.KeepHeaders(true)
+	var readErr error
+	pr.SetWarn(func(offset int64, err error) {
+		diags = append(diags, Diagnostic{Error, offset, -1, -1, 0, err.Error()})
+	})
+	v := validator{windows: make(map[uint8]Window), palettes: make(map[uint8]bool), objects: make(map[uint16]*Image)}
+	for i := 0; ; i++ {
+		ds, err := pr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		size := int64(0)
+		for _, h := range ds.Headers {
+			size += headerSize + int64(h.SegmentSize)
+		}
+		v.ds, v.i, v.offset = ds, i, pr.r.n-size
+		v.check()
+		diags = append(diags, v.diags...)
+		v.diags = v.diags[:0]
+	}
+	if v.ds != nil && !v.ds.IsClear() {
+		v.report(Warning, 0, "stream ends without clearing epoch")
+		diags = append(diags, v.diags...)
+	}
+	return diags, readErr
+}
+
+// validator checks display sets against the state of their epoch.
+type validator struct {
+	ds       *DisplaySet
+	i        int
+	offset   int64 // Offset of the display set
+	prev     *DisplaySet
+	windows  map[uint8]Window
+	palettes map[uint8]bool
+	objects  map[uint16]*Image
+	diags    []Diagnostic
+}
+
+// report adds a diagnostic for segment seg of the display set.
+func (v *validator) report(s Severity, seg int, format string, args ...any) {
+	off := v.offset
+	for _, h := range v.ds.Headers[:seg] {
+		off += headerSize + int64(h.SegmentSize)
+	}
+	v.diags = append(v.diags, Diagnostic{s, off, v.i, seg, v.ds.PresentationTime, fmt.Sprintf(format, args...)})
+}
+
+// segment returns the index of the first segment of the type in the
+// display set, or 0, the PCS, if there is none.
+func (v *validator) segment(typ SegmentType) int {
+	for j, h := range v.ds.Headers {
+		if h.SegmentType == typ {
+			return j
+		}
+	}
+	return 0
+}
+
+func (v *validator) check() {
+	ds := v.ds
+	if prev := v.prev; prev != nil {
+		if ds.PresentationTime < prev.PresentationTime {
+			v.report(Error, 0, "presentation time before previous %s", prev.PresentationTime)
+		}
+		if ds.CompositionNumber != prev.CompositionNumber+1 {
+			v.report(Warning, 0, "composition number %d does not follow %d", ds.CompositionNumber, prev.CompositionNumber)
+		}
+		if ds.CompositionState == EpochStart && !prev.IsClear() {
+			v.report(Warning, 0, "epoch starts without clearing previous epoch")
+		}
+		if ds.CompositionState != EpochStart && (ds.Width != prev.Width || ds.Height != prev.Height) {
+			v.report(Error, 0, "video dimensions %dx%d changed from %dx%d within epoch", ds.Width, ds.Height, prev.Width, prev.Height)
+		}
+	} else if ds.CompositionState != EpochStart {
+		v.report(Error, 0, "stream does not begin with EpochStart")
+	}
+	v.prev = ds
+
+	if ds.CompositionState == EpochStart {
+		clear(v.windows)
+		clear(v.palettes)
+		clear(v.objects)
+	}
+	if len(ds.Windows) != 0 {
+		seg := v.segment(WDSType)
+		if err := ds.ValidateWindows(); err != nil {
+			v.report(Error, seg, "%v", err)
+		}
+		for j, w := range ds.Windows {
+			for k, prev := range ds.Windows[:j] {
+				if w.Rect().Overlaps(prev.Rect()) {
+					v.report(Error, seg, "window %d/%d overlaps window %d", j+1, len(ds.Windows), k+1)
+				}
+			}
+			v.windows[w.ID] = w
+		}
+	}
+	for _, p := range ds.DefinedPalettes() {
+		v.palettes[p.ID] = true
+	}
+	for _, obj := range ds.DefinedObjects() {
+		if obj.First && obj.Last {
+			if err := obj.VerifyDecode(); err != nil {
+				v.report(Error, v.segment(ODSType), "object %d: %v", obj.ID, err)
+			}
+		} else {
+			v.report(Error, v.segment(ODSType), "object %d: incomplete sequence of fragments", obj.ID)
+		}
+		v.objects[obj.ID] = &obj.Image
+	}
+
+	if (len(ds.Objects) != 0 || ds.PaletteUpdate) && !v.palettes[ds.PaletteID] {
+		v.report(Error, 0, "palette %d not defined in epoch", ds.PaletteID)
+	}
+	frame := image.Rect(0, 0, int(ds.Width), int(ds.Height))
+	for j := range ds.Objects {
+		co := &ds.Objects[j]
+		win, ok := v.windows[co.WindowID]
+		if !ok {
+			v.report(Error, 0, "composition object %d/%d: window %d not defined in epoch", j+1, len(ds.Objects), co.WindowID)
+		}
+		img, ok := v.objects[co.ObjectID]
+		if !ok {
+			v.report(Error, 0, "composition object %d/%d: object %d not defined in epoch", j+1, len(ds.Objects), co.ObjectID)
+			continue
+		}
+		r := co.rect(img)
+		if !r.In(frame) {
+			v.report(Error, 0, "composition object %d/%d: %v outside %dx%d frame", j+1, len(ds.Objects), r, ds.Width, ds.Height)
+		}
+		if win.Width != 0 && !r.Empty() && !r.In(win.Rect()) {
+			v.report(Warning, 0, "composition object %d/%d: %v extends beyond window %d", j+1, len(ds.Objects), r, co.WindowID)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// ContentBounds returns the smallest rectangle in frame coordinates
+// containing all non-transparent pixels of the composition objects. A
+// display set without composition objects has zero bounds. Objects and
+// the palette defined earlier in the epoch are resolved when the display
+// set was read by a Reader.
+func (ds *DisplaySet) ContentBounds() (image.Rectangle, error) {
+	objects, p, err := ds.shown(nil)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	var bounds image.Rectangle
+	for i, obj := range objects {
+		co := &ds.Objects[i]
+		r, err := obj.bounds(p)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("object %d: %w", obj.ID, err)
+		}
+		pt := image.Point{int(co.X), int(co.Y)}
+		bounds = bounds.Union(r.Add(pt).Intersect(co.rect(&obj.Image)))
+	}
+	return bounds, nil
+}
+
+// FrameSize returns the video dimensions declared by the composition.
+func (ds *DisplaySet) FrameSize() (width, height int) {
+	return int(ds.Width), int(ds.Height)
+}
+
+// Validate checks the windows of the display set, as in ValidateWindows,
+// and that its objects, except fragments of sequences, decode to their
+// declared dimensions, as in Object.VerifyDecode.
+func (ds *DisplaySet) Validate() error {
+	if err := ds.ValidateWindows(); err != nil {
+		return err
+	}
+	for _, obj := range ds.DefinedObjects() {
+		if obj.First && obj.Last {
+			if err := obj.VerifyDecode(); err != nil {
+				return fmt.Errorf("object %d: %w", obj.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateWindows checks that the windows of the display set are not
+// degenerate in its video frame, as in Window.IsDegenerate, and have
+// distinct IDs and geometry.
+func (ds *DisplaySet) ValidateWindows() error {
+	for i, w := range ds.Windows {
+		for j, prev := range ds.Windows[:i] {
+			if prev.ID == w.ID {
+				return fmt.Errorf("window %d/%d: id %d reused from window %d", i+1, len(ds.Windows), w.ID, j+1)
+			}
+			if prev.Rect() == w.Rect() {
+				return fmt.Errorf("window %d/%d: %v duplicates window %d", i+1, len(ds.Windows), w.Rect(), j+1)
+			}
+		}
+		if w.IsDegenerate(int(ds.Width), int(ds.Height)) {
+			return fmt.Errorf("window %d/%d: degenerate %v in %dx%d frame", i+1, len(ds.Windows), w.Rect(), ds.Width, ds.Height)
+		}
+	}
+	return nil
+}
+
+// Rect returns the area of the window in frame coordinates.
+func (w Window) Rect() image.Rectangle {
+	return image.Rect(int(w.X), int(w.Y), int(w.X)+int(w.Width), int(w.Y)+int(w.Height))
+}
+
+// WindowOverflow is the number of pixels by which a composition object
+// extends beyond each edge of its window.
+type WindowOverflow struct {
+	ObjectID                 uint16
+	WindowID                 uint8
+	Left, Top, Right, Bottom int
+}
+
+// ObjectsExceedingWindows returns the composition objects with visible
+// bitmaps, after cropping, extending beyond the windows they are placed
+// in, which decoders clip. Objects must be defined in the display set
+// itself.
+func (ds *DisplaySet) ObjectsExceedingWindows() ([]WindowOverflow, error) {
+	var overflows []WindowOverflow
+	for i := range ds.Objects {
+		co := &ds.Objects[i]
+		obj, err := ds.object(co.ObjectID)
+		if err != nil {
+			return nil, fmt.Errorf("composition object %d/%d: %w", i+1, len(ds.Objects), err)
+		}
+		var win *Window
+		for j := range ds.Windows {
+			if ds.Windows[j].ID == co.WindowID {
+				win = &ds.Windows[j]
+			}
+		}
+		if win == nil {
+			return nil, fmt.Errorf("composition object %d/%d: window %d not defined in display set", i+1, len(ds.Objects), co.WindowID)
+		}
+		r, wr := co.rect(&obj.Image), win.Rect()
+		if r.Empty() || r.In(wr) {
+			continue
+		}
+		overflows = append(overflows, WindowOverflow{
+			ObjectID: co.ObjectID,
+			WindowID: co.WindowID,
+			Left:     max(wr.Min.X-r.Min.X, 0),
+			Top:      max(wr.Min.Y-r.Min.Y, 0),
+			Right:    max(r.Max.X-wr.Max.X, 0),
+			Bottom:   max(r.Max.Y-wr.Max.Y, 0),
+		})
+	}
+	return overflows, nil
+}
+
+// IsDegenerate reports whether the window has zero width or height or
+// extends beyond a video frame of the given dimensions, which usually
+// indicates corruption.
+func (w Window) IsDegenerate(width, height int) bool {
+	return w.Width == 0 || w.Height == 0 || !w.Rect().In(image.Rect(0, 0, width, height))
+}
+
+// Placement is the vertical position of subtitle content in the frame.
+type Placement uint8
+
+const (
+	Bottom Placement = iota
+	Middle
+	Top
+)
+
+// VerticalPlacement classifies the content of the display set by which
+// third of the frame contains the center of its bounds. Objects and the
+// palette defined earlier in the epoch are resolved when the display set
+// was read by a Reader, and composition objects that cannot be resolved
+// are ignored. Objects whose data cannot be decoded are taken as
+// entirely visible. Display sets without content are placed at the
+// bottom.
+func (ds *DisplaySet) VerticalPlacement() Placement {
+	var bounds image.Rectangle
+	p := ds.ResolvePalette()
+	for i := range ds.Objects {
+		co := &ds.Objects[i]
+		obj := ds.ResolveObject(co.ObjectID)
+		if obj == nil {
+			continue
+		}
+		r := co.rect(&obj.Image)
+		if p != nil {
+			if b, err := obj.bounds(p); err == nil {
+				r = b.Add(image.Point{int(co.X), int(co.Y)}).Intersect(r)
+			}
+		}
+		bounds = bounds.Union(r)
+	}
+	if bounds.Empty() || ds.Height == 0 {
+		return Bottom
+	}
+	center := (bounds.Min.Y + bounds.Max.Y) / 2
+	switch h := int(ds.Height); {
+	case center < h/3:
+		return Top
+	case center < h*2/3:
+		return Middle
+	}
+	return Bottom
+}
+
+// ASSAlignment returns the numpad-style alignment used by the \an
+// override tag in ASS/SSA subtitles for horizontally centered text.
+func (p Placement) ASSAlignment() int {
+	switch p {
+	case Top:
+		return 8
+	case Middle:
+		return 5
+	}
+	return 2
+}
+
+func (p Placement) String() string {
+	switch p {
+	case Bottom:
+		return "bottom"
+	case Middle:
+		return "middle"
+	case Top:
+		return "top"
+	}
+	return fmt.Sprintf("Placement(%d)", uint8(p))
+}
+
+// DefinedPalettes returns the palettes defined by the display set,
+// Palette followed by ExtraPalettes.
+func (ds *DisplaySet) DefinedPalettes() []*Palette {
+	var palettes []*Palette
+	if ds.Palette != nil {
+		palettes = append(palettes, ds.Palette)
+	}
+	for i := range ds.ExtraPalettes {
+		palettes = append(palettes, &ds.ExtraPalettes[i])
+	}
+	return palettes
+}
+
+// DefinedObjects returns the objects defined by the display set, Object
+// followed by ExtraObjects.
+func (ds *DisplaySet) DefinedObjects() []*Object {
+	var objects []*Object
+	if ds.Object != nil {
+		objects = append(objects, ds.Object)
+	}
+	for i := range ds.ExtraObjects {
+		objects = append(objects, &ds.ExtraObjects[i])
+	}
+	return objects
+}
+
+// definePalette adds a palette definition to the display set.
+func (ds *DisplaySet) definePalette(p *Palette) {
+	if ds.Palette == nil {
+		ds.Palette = p
+	} else {
+		ds.ExtraPalettes = append(ds.ExtraPalettes, *p)
+	}
+}
+
+// defineObject adds an object definition to the display set.
+func (ds *DisplaySet) defineObject(obj *Object) {
+	if ds.Object == nil {
+		ds.Object = obj
+	} else {
+		ds.ExtraObjects = append(ds.ExtraObjects, *obj)
+	}
+}
+
+// object returns the object with the given ID defined in the display
+// set.
+func (ds *DisplaySet) object(id uint16) (*Object, error) {
+	for _, obj := range ds.DefinedObjects() {
+		if obj.ID == id {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("object %d not defined in display set", id)
+}
+
+// palette returns the palette used by the display set, the one defined
+// with its palette ID, or Palette, if none has the ID, for streams that
+// define the wrong ID.
+func (ds *DisplaySet) palette() *Palette {
+	for _, p := range ds.DefinedPalettes() {
+		if p.ID == ds.PaletteID {
+			return p
+		}
+	}
+	return ds.Palette
+}
+
+// ResolveObject returns the object with the given ID defined in the
+// display set, or else earlier in its epoch, when the display set was
+// read by a Reader, or nil.
+func (ds *DisplaySet) ResolveObject(id uint16) *Object {
+	if obj, err := ds.object(id); err == nil {
+		return obj
+	}
+	if ds.prior != nil {
+		return ds.prior.objects[id]
+	}
+	return nil
+}
+
+// ResolvePalette returns the palette used by the display set, the one
+// it defines with its palette ID, or else the one with its palette ID
+// defined earlier in its epoch, when the display set was read by a
+// Reader, or else Palette, for streams that define the wrong ID.
+func (ds *DisplaySet) ResolvePalette() *Palette {
+	for _, p := range ds.DefinedPalettes() {
+		if p.ID == ds.PaletteID {
+			return p
+		}
+	}
+	if ds.prior != nil {
+		if p := ds.prior.palettes[ds.PaletteID]; p != nil {
+			return p
+		}
+	}
+	return ds.Palette
+}
+
+// shown returns the objects shown by the composition objects of the
+// display set, in order, and the palette they use, resolved as in
+// ResolveObject and ResolvePalette, or fallback, if the palette is not
+// defined.
+func (ds *DisplaySet) shown(fallback *Palette) ([]*Object, *Palette, error) {
+	if len(ds.Objects) == 0 {
+		return nil, nil, nil
+	}
+	objects := make([]*Object, len(ds.Objects))
+	for i, co := range ds.Objects {
+		if objects[i] = ds.ResolveObject(co.ObjectID); objects[i] == nil {
+			return nil, nil, fmt.Errorf("composition object %d/%d: object %d not defined in epoch",
+				i+1, len(ds.Objects), co.ObjectID)
+		}
+	}
+	p := ds.ResolvePalette()
+	if p == nil {
+		p = fallback
+	}
+	if p == nil {
+		return nil, nil, fmt.Errorf("palette %d not defined in epoch", ds.PaletteID)
+	}
+	return objects, p, nil
+}
+
+// rect returns the area of the frame covered by the composition object
+// when displaying img, after cropping.
+func (co *CompositionObject) rect(img *Image) image.Rectangle {
+	r := image.Rect(int(co.X), int(co.Y), int(co.X)+int(img.Width), int(co.Y)+int(img.Height))
+	if co.Crop != nil {
+		r = r.Intersect(co.Crop.Rect())
+	}
+	return r
+}
+
+// Rect returns the cropping rectangle in frame coordinates.
+func (crop *CompositionObjectCrop) Rect() image.Rectangle {
+	return image.Rect(int(crop.X), int(crop.Y),
+		int(crop.X)+int(crop.Width), int(crop.Y)+int(crop.Height))
+}
+
+// SegmentTiming is the raw timing of one segment of a display set.
+type SegmentTiming struct {
+	Type             SegmentType
+	PresentationTime Timestamp
+	DecodingTime     Timestamp
+}
+
+// Lead returns how far decoding of the segment starts before its
+// presentation.
+func (st SegmentTiming) Lead() time.Duration {
+	return st.PresentationTime.Sub(st.DecodingTime)
+}
+
+// SegmentTimings returns the timing of each segment of the display set,
+// in stream order. It is only available when the Reader kept headers and
+// is nil otherwise.
+func (ds *DisplaySet) SegmentTimings() []SegmentTiming {
+	if ds.Headers == nil {
+		return nil
+	}
+	timings := make([]SegmentTiming, len(ds.Headers))
+	for i, h := range ds.Headers {
+		timings[i] = SegmentTiming{h.SegmentType, h.PresentationTime, h.DecodingTime}
+	}
+	return timings
+}
+
+// SegmentCount returns the number of segments of the display set, those
+// read when the Reader kept headers, and otherwise those that a Writer
+// writes, including the fragments of large objects.
+func (ds *DisplaySet) SegmentCount() int {
+	if ds.Headers != nil {
+		return len(ds.Headers)
+	}
+	n := 2 + len(ds.DefinedPalettes()) + len(ds.Vendor) // With PCS and END
+	if len(ds.Windows) != 0 {
+		n++
+	}
+	for _, obj := range ds.DefinedObjects() {
+		dataLen := len(obj.Data)
+		if obj.Data == nil {
+			dataLen = obj.DataLen
+		}
+		n += len(obj.fragmentSizes(dataLen))
+	}
+	return n
+}
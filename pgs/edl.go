@@ -0,0 +1,42 @@
+package pgs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportEDL reads the stream and writes the timing of each subtitle as
+// CSV, with columns for the index, the in and out SMPTE timecodes at fps
+// frames per second, and a thumbnail filename named as by ass.ToASS, for
+// importing timing into video editors. When fps is not positive, the
+// frame rate of each composition is used.
+func ExportEDL(r *Reader, w io.Writer, fps float64) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"index", "in", "out", "thumbnail"}); err != nil {
+		return err
+	}
+	i := 0
+	for iv, err := range r.Intervals() {
+		if err != nil {
+			return err
+		}
+		rate, err := iv.DisplaySet.FPS(fps)
+		if err != nil {
+			return fmt.Errorf("display set at %s: %w", iv.Start, err)
+		}
+		i++
+		record := []string{
+			strconv.Itoa(i),
+			Timecode(iv.Start, rate),
+			Timecode(iv.End, rate),
+			fmt.Sprintf("sub_%d.png", i),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,290 @@
+package pgs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"io"
+	"time"
+)
+
+// Epoch is a sequence of display sets starting with an EpochStart
+// composition, within which palettes and objects persist. Object and
+// palette IDs are scoped to the epoch, so definitions in one epoch never
+// resolve references in another.
+type Epoch struct {
+	DisplaySets []DisplaySet
+}
+
+// EpochReader groups the display sets read from a Reader into epochs.
+type EpochReader struct {
+	r    *Reader
+	next *DisplaySet
+}
+
+func NewEpochReader(r *Reader) *EpochReader {
+	return &EpochReader{r: r}
+}
+
+// Read reads display sets up to the next EpochStart composition. Display
+// sets before the first EpochStart in the stream form their own epoch.
+func (er *EpochReader) Read() (*Epoch, error) {
+	var e Epoch
+	if er.next != nil {
+		e.DisplaySets = append(e.DisplaySets, *er.next)
+		er.next = nil
+	}
+	for {
+		ds, err := er.r.Read()
+		if err == io.EOF && len(e.DisplaySets) != 0 {
+			return &e, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.CompositionState == EpochStart && len(e.DisplaySets) != 0 {
+			er.next = ds
+			return &e, nil
+		}
+		e.DisplaySets = append(e.DisplaySets, *ds)
+	}
+}
+
+// Resolve returns a copy of display set i of the epoch with the palette
+// it uses and the objects it shows taken from the latest definitions in
+// the epoch up to it, so that it can be rendered although it does not
+// define them itself. The first object shown is Object and the others
+// are ExtraObjects, in the order of the composition. Definitions are
+// resolved as by a Reader, from the display sets of the epoch, so the
+// epoch may be modified after it is read.
+func (e *Epoch) Resolve(i int) (*DisplaySet, error) {
+	if i < 0 || i >= len(e.DisplaySets) {
+		return nil, fmt.Errorf("display set %d out of range", i)
+	}
+	ds := e.DisplaySets[i]
+	ds.prior = &epochDefs{}
+	for j := range i {
+		if e.DisplaySets[j].CompositionState == EpochStart {
+			ds.prior = &epochDefs{}
+		}
+		ds.prior = ds.prior.with(&e.DisplaySets[j])
+	}
+	if ds.CompositionState == EpochStart {
+		ds.prior = &epochDefs{}
+	}
+	if len(ds.Objects) == 0 {
+		return &ds, nil
+	}
+	p := ds.ResolvePalette()
+	if p == nil {
+		return nil, fmt.Errorf("display set %d: palette %d not defined in epoch", i, ds.PaletteID)
+	}
+	objects := make([]*Object, len(ds.Objects))
+	for j, co := range ds.Objects {
+		if objects[j] = ds.ResolveObject(co.ObjectID); objects[j] == nil {
+			return nil, fmt.Errorf("display set %d: object %d not defined in epoch", i, co.ObjectID)
+		}
+	}
+	ds.Palette, ds.Object = p, nil
+	ds.ExtraPalettes, ds.ExtraObjects = nil, nil
+	for _, obj := range objects {
+		if _, err := ds.object(obj.ID); err != nil {
+			ds.defineObject(obj)
+		}
+	}
+	return &ds, nil
+}
+
+// At returns the resolved display set on screen at t, as with Resolve,
+// or nil if nothing from the epoch is shown at t. Palette updates
+// before t apply their palette to the shown objects.
+func (e *Epoch) At(t time.Duration) (*DisplaySet, error) {
+	last := -1
+	for i := range e.DisplaySets {
+		if e.DisplaySets[i].PresentationTime > t {
+			break
+		}
+		last = i
+	}
+	if last < 0 || e.DisplaySets[last].IsClear() {
+		return nil, nil
+	}
+	return e.Resolve(last)
+}
+
+// ObjectLifetimes returns, for each object ID referenced by compositions
+// in the epoch, the span from the first display set referencing it to
+// the display set after the last one referencing it, or the last display
+// set, if it is referenced to the end. The display set of each interval
+// is the first referencing the object.
+func (e *Epoch) ObjectLifetimes() map[uint16]Interval {
+	lifetimes := make(map[uint16]Interval)
+	last := make(map[uint16]int)
+	for i := range e.DisplaySets {
+		ds := &e.DisplaySets[i]
+		for _, obj := range ds.Objects {
+			if _, ok := lifetimes[obj.ObjectID]; !ok {
+				lifetimes[obj.ObjectID] = Interval{Start: ds.PresentationTime, DisplaySet: ds}
+			}
+			last[obj.ObjectID] = i
+		}
+	}
+	for id, i := range last {
+		iv := lifetimes[id]
+		if i+1 < len(e.DisplaySets) {
+			i++
+		}
+		iv.End = e.DisplaySets[i].PresentationTime
+		lifetimes[id] = iv
+	}
+	return lifetimes
+}
+
+// ValidateObjectVersions checks that objects redefined within the epoch
+// with different data also have a different version, since decoders may
+// otherwise display a stale cached bitmap. Objects are compared by their
+// dimensions, data length, and a hash of their data, which is empty for
+// objects read with SkipObjectData, so that redefinitions with data of
+// the same length are not detected in that case.
+func (e *Epoch) ValidateObjectVersions() error {
+	type def struct {
+		version       uint8
+		width, height uint16
+		dataLen       int
+		hash          [sha256.Size]byte
+	}
+	defs := make(map[uint16]def)
+	var ids []uint16
+	reported := make(map[uint16]bool)
+	for i := range e.DisplaySets {
+		for _, obj := range e.DisplaySets[i].DefinedObjects() {
+			h := sha256.New()
+			if _, err := io.Copy(h, obj.DataReader()); err != nil {
+				return fmt.Errorf("object %d: %w", obj.ID, err)
+			}
+			d := def{obj.Version, obj.Width, obj.Height, obj.DataLen, [sha256.Size]byte(h.Sum(nil))}
+			prev, ok := defs[obj.ID]
+			if ok && prev.version == d.version && prev != d && !reported[obj.ID] {
+				ids = append(ids, obj.ID)
+				reported[obj.ID] = true
+			}
+			defs[obj.ID] = d
+		}
+	}
+	if len(ids) != 0 {
+		return fmt.Errorf("objects redefined with different data but same version: %v", ids)
+	}
+	return nil
+}
+
+// UnclearedEpochStart is an EpochStart display set showing content while
+// the previous epoch still showed objects.
+type UnclearedEpochStart struct {
+	Time      time.Duration // Presentation time of the EpochStart
+	ObjectIDs []uint16      // Objects of the previous epoch never cleared
+}
+
+// UnclearedEpochStarts reads the stream and reports each EpochStart
+// display set with composition objects that was not preceded by a clear
+// of the previous epoch. Decoders that do not fully reset at the start
+// of an epoch may show stale pixels in that case.
+func UnclearedEpochStarts(r *Reader) ([]UnclearedEpochStart, error) {
+	var reports []UnclearedEpochStart
+	var shown *DisplaySet
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return reports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.CompositionState == EpochStart && !ds.IsClear() && shown != nil {
+			ids := make([]uint16, len(shown.Objects))
+			for i, co := range shown.Objects {
+				ids[i] = co.ObjectID
+			}
+			reports = append(reports, UnclearedEpochStart{ds.PresentationTime, ids})
+		}
+		shown = ds
+		if ds.IsClear() {
+			shown = nil
+		}
+	}
+}
+
+// EndsClear reports whether the last display set of the epoch clears the
+// screen.
+func (e *Epoch) EndsClear() bool {
+	return len(e.DisplaySets) != 0 && e.DisplaySets[len(e.DisplaySets)-1].IsClear()
+}
+
+// UnclearedEpoch is an epoch whose content was still shown when it ended.
+type UnclearedEpoch struct {
+	Start time.Duration // Presentation time of the first display set
+	End   time.Duration // Presentation time of the last display set
+	Last  *DisplaySet   // Last display set, which is not a clear
+}
+
+// UnclearedEpochs reads the stream by epoch and reports each epoch that
+// does not end with a clear display set, as authoring guidelines require
+// before the next EpochStart. The final epoch is reported when the stream
+// ends with content still shown. Unlike UnclearedEpochStarts, epochs are
+// reported even when the next epoch starts with a clear.
+func UnclearedEpochs(r *Reader) ([]UnclearedEpoch, error) {
+	er := NewEpochReader(r)
+	var reports []UnclearedEpoch
+	for {
+		e, err := er.Read()
+		if err == io.EOF {
+			return reports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !e.EndsClear() {
+			last := &e.DisplaySets[len(e.DisplaySets)-1]
+			reports = append(reports, UnclearedEpoch{e.DisplaySets[0].PresentationTime, last.PresentationTime, last})
+		}
+	}
+}
+
+// EpochThumbnail is a representative frame of an epoch.
+type EpochThumbnail struct {
+	Start time.Duration // Presentation time of the first display set of the epoch
+	Time  time.Duration // Presentation time of the rendered display set
+	Image *image.RGBA
+}
+
+// EpochThumbnails reads the stream by epoch and renders the first display
+// set of each epoch that shows objects, resolved with the palette and
+// objects defined earlier in the epoch as with Epoch.Resolve, for a
+// scene-level overview of the stream. Display sets showing objects not
+// yet defined are skipped, as are epochs without any shown display set.
+func EpochThumbnails(r *EpochReader) ([]EpochThumbnail, error) {
+	var thumbs []EpochThumbnail
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return thumbs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range e.DisplaySets {
+			if e.DisplaySets[i].IsClear() {
+				continue
+			}
+			ds, err := e.Resolve(i)
+			if err != nil {
+				continue
+			}
+			img, err := ds.Render()
+			if err != nil {
+				return nil, fmt.Errorf("display set at %s: %w", ds.PresentationTime, err)
+			}
+			thumbs = append(thumbs, EpochThumbnail{e.DisplaySets[0].PresentationTime, ds.PresentationTime, img})
+			break
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEpochScopedIDs(t *testing.T) {
+	var stream []DisplaySet
+	for i, c := range []color.Color{color.White, color.Black} {
+		img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Transparent, c})
+		img.SetColorIndex(i, i, 1)
+		show, err := NewDisplaySet(img, 1, 1, time.Duration(2*i+1)*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clear := NewClearDisplaySet(time.Duration(2*i+2)*time.Second, 0)
+		for _, ds := range []*DisplaySet{show, clear} {
+			ds.Width, ds.Height = 4, 4
+			ds.CompositionNumber = uint16(len(stream))
+			stream = append(stream, *ds)
+		}
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	er := NewEpochReader(NewReader(bytes.NewReader(b.Bytes())))
+	var epochs []*Epoch
+	for {
+		e, err := er.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs = append(epochs, e)
+	}
+	if len(epochs) != 2 {
+		t.Fatalf("got %d epochs, want 2", len(epochs))
+	}
+	for i, e := range epochs {
+		// Object 0 version 0 is redefined in the second epoch, which is
+		// only valid because IDs do not carry over
+		if err := e.ValidateObjectVersions(); err != nil {
+			t.Errorf("epoch %d: %v", i, err)
+		}
+		img, err := e.DisplaySets[0].Render()
+		if err != nil {
+			t.Fatalf("epoch %d: %v", i, err)
+		}
+		want := color.RGBAModel.Convert(stream[2*i].Palette.Entries[1]).(color.RGBA)
+		if got := img.RGBAAt(1+i, 1+i); got != want {
+			t.Errorf("epoch %d: pixel is %v, want %v", i, got, want)
+		}
+	}
+
+	merged := Epoch{append(epochs[0].DisplaySets, epochs[1].DisplaySets...)}
+	if err := merged.ValidateObjectVersions(); err == nil {
+		t.Error("redefinition within one epoch not detected")
+	}
+}
+
+func TestEpochResolve(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Transparent, color.White})
+	img.Pix[0] = 1
+	show, err := NewDisplaySet(img, 1, 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A palette update recoloring the object without defining it again
+	update := NewClearDisplaySet(2*time.Second, 0)
+	update.PaletteUpdate = true
+	update.Objects = show.Objects
+	black := *show.Palette
+	black.Version = 1
+	black.Entries = []PaletteEntry{black.Entries[0],
+		{ID: 1, NYCbCrA: color.NYCbCrA{YCbCr: color.YCbCr{Y: 0, Cb: 128, Cr: 128}, A: 0xff}}}
+	update.Palette = &black
+	stream := []DisplaySet{*show, *update, *NewClearDisplaySet(3*time.Second, 0)}
+	for i := range stream {
+		stream[i].Width, stream[i].Height = 4, 4
+		stream[i].CompositionNumber = uint16(i)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	read, err := NewEpochReader(NewReader(&b)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Epochs built without a Reader resolve the same as those read
+	for name, e := range map[string]*Epoch{"built": {stream}, "read": read} {
+		ds, err := e.Resolve(1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if ds.Palette == nil || ds.Palette.Version != 1 || ds.Object == nil || ds.Object.ID != 0 {
+			t.Errorf("%s: resolved palette %v and object %v, want palette version 1 and object 0", name, ds.Palette, ds.Object)
+		}
+		img, err := ds.Render()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := img.RGBAAt(1, 1); got != (color.RGBA{0, 0, 0, 0xff}) {
+			t.Errorf("%s: pixel is %v, want black", name, got)
+		}
+		if ds, err := e.At(3 * time.Second); err != nil || ds != nil {
+			t.Errorf("%s: At cleared screen = %v, %v, want nil", name, ds, err)
+		}
+	}
+}
+
+func TestEpochThumbnails(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Transparent, color.White})
+	img.Pix[0] = 1
+	// The EpochStart only defines the object, and the palette and
+	// composition come later
+	setup, err := NewDisplaySet(img, 1, 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show := NewClearDisplaySet(2*time.Second, 0)
+	show.Objects, show.Palette = setup.Objects, setup.Palette
+	setup.Objects, setup.Palette = nil, nil
+	stream := []DisplaySet{*setup, *show, *NewClearDisplaySet(3*time.Second, 0)}
+	for i := range stream {
+		stream[i].Width, stream[i].Height = 4, 4
+		stream[i].CompositionNumber = uint16(i)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	thumbs, err := EpochThumbnails(NewEpochReader(NewReader(&b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thumbs) != 1 || thumbs[0].Start != time.Second || thumbs[0].Time != 2*time.Second {
+		t.Fatalf("got %d thumbnails, want one at 2s of the epoch at 1s", len(thumbs))
+	}
+	if got := thumbs[0].Image.RGBAAt(1, 1); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("pixel is %v, want white", got)
+	}
+}
+
+func TestValidateObjectVersionsExtraObjects(t *testing.T) {
+	p := color.Palette{color.Transparent, color.White}
+	var stream []DisplaySet
+	for i := range 2 {
+		first := image.NewPaletted(image.Rect(0, 0, 2, 2), p)
+		second := image.NewPaletted(image.Rect(0, 0, 2, 2), p)
+		// Only the second object changes
+		first.Pix[0], second.Pix[i] = 1, 1
+		ds, err := NewDisplaySet(first, 0, 0, time.Duration(i+1)*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := EncodeRLE(second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj := *ds.Object
+		obj.ID, obj.Image, obj.DataLen = 1, *img, len(img.Data)
+		ds.ExtraObjects = []Object{obj}
+		ds.Objects = append(ds.Objects, CompositionObject{ObjectID: 1, X: 2})
+		if i != 0 {
+			ds.CompositionState = Normal
+		}
+		ds.Width, ds.Height = 4, 2
+		ds.CompositionNumber = uint16(i)
+		stream = append(stream, *ds)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	for _, skip := range []bool{false, true} {
+		r := NewReader(bytes.NewReader(b.Bytes()))
+		r.SkipObjectData(skip)
+		e, err := NewEpochReader(r).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = e.ValidateObjectVersions()
+		// Skipped data of the same length cannot be compared
+		if skip && err != nil {
+			t.Errorf("skipped data: %v", err)
+		}
+		if !skip && (err == nil || !strings.Contains(err.Error(), "[1]")) {
+			t.Errorf("got %v, want object 1 reported", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package pgs
+
+import "io"
+
+// UsesCropping reads the stream and reports whether any composition
+// object has a crop rectangle.
+func UsesCropping(r *Reader) (bool, error) {
+	return uses(r, func(ds *DisplaySet) bool {
+		for _, co := range ds.Objects {
+			if co.Crop != nil {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// UsesPaletteUpdates reads the stream and reports whether any display set
+// is a palette update.
+func UsesPaletteUpdates(r *Reader) (bool, error) {
+	return uses(r, func(ds *DisplaySet) bool {
+		return ds.PaletteUpdate
+	})
+}
+
+// UsesMultipleWindows reads the stream and reports whether any display
+// set defines more than one window.
+func UsesMultipleWindows(r *Reader) (bool, error) {
+	return uses(r, func(ds *DisplaySet) bool {
+		return len(ds.Windows) > 1
+	})
+}
+
+// uses reads the stream until a display set satisfies the feature,
+// without reading object data, restoring the option of r on return.
+func uses(r *Reader, feature func(ds *DisplaySet) bool) (bool, error) {
+	defer r.SkipObjectData(r.skipData)
+	r.SkipObjectData(true)
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if feature(ds) {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func FuzzReadSegment(f *testing.F) {
+	f.Add([]byte("PG\x00\x00\x00\x00\x00\x00\x00\x00\x16\x00\x0b\x00\x14\x00\x0a\x10\x00\x00\x80\x00\x00\x00" +
+		"PG\x00\x00\x00\x00\x00\x00\x00\x00\x80\x00\x00"))
+	f.Add(hugeObjectStream())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		r := NewReader(bytes.NewReader(b))
+		for {
+			ds, err := r.Read()
+			if err != nil {
+				return
+			}
+			for _, obj := range ds.DefinedObjects() {
+				obj.UsedIndices()
+				if obj.First && obj.Last {
+					obj.Convert(&Palette{})
+				}
+			}
+		}
+	})
+}
+
+// hugeObjectStream returns a stream with a 65535x65535 object of 65535
+// empty lines, which must be rejected without allocating the image.
+func hugeObjectStream() []byte {
+	var b bytes.Buffer
+	segment := func(typ SegmentType, payload []byte) {
+		binary.Write(&b, binary.BigEndian, Header{MagicNumber: 0x5047, SegmentType: typ, SegmentSize: uint16(len(payload))})
+		b.Write(payload)
+	}
+	segment(PCSType, []byte("\x00\x14\x00\x0a\x10\x00\x00\x80\x00\x00\x00"))
+	data := make([]byte, 0xffff*2)
+	first := maxFirstFragment
+	segment(ODSType, append([]byte{0, 0, 0, byte(firstInSequence), 0x02, 0x00, 0x02, 0xff, 0xff, 0xff, 0xff}, data[:first]...))
+	for data = data[first:]; len(data) > maxFragment; data = data[maxFragment:] {
+		segment(ODSType, append([]byte{0, 0, 0, 0}, data[:maxFragment]...))
+	}
+	segment(ODSType, append([]byte{0, 0, 0, byte(lastInSequence)}, data...))
+	segment(ENDType, nil)
+	return b.Bytes()
+}
@@ -0,0 +1,82 @@
+package pgs
+
+import (
+	"image"
+	"math/bits"
+)
+
+// HashMatchThreshold is the recommended maximum HashDistance between the
+// perceptual hashes of display sets showing the same subtitle. Copies
+// that differ in anti-aliasing, palette rounding, or slight scaling
+// usually differ by under 5 bits, while different text differs by 20 or
+// more.
+const HashMatchThreshold = 10
+
+// PerceptualHash returns a difference hash (dHash) of the rendered
+// content, for fuzzy matching of subtitles across differently encoded
+// copies. The content bounds are shrunk to a 9x8 grid of grayscale
+// averages, composited over black, and each of the 64 bits is set when a
+// cell is darker than the cell to its right. Since only the content is
+// hashed, the hash does not depend on its position in the frame. A
+// display set without content hashes to 0. Objects and the palette must
+// be defined in the display set itself.
+func (ds *DisplaySet) PerceptualHash() (uint64, error) {
+	bounds, err := ds.ContentBounds()
+	if err != nil || bounds.Empty() {
+		return 0, err
+	}
+	img, err := ds.Render()
+	if err != nil {
+		return 0, err
+	}
+	var grid [8][9]uint32
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = averageGray(img, gridCell(bounds, x, y, 9, 8))
+		}
+	}
+	var hash uint64
+	for y := range grid {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grid[y][x] < grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// HashDistance returns the number of bits that differ between two
+// perceptual hashes.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// gridCell returns the cell at column x and row y of r divided into a
+// grid of w by h cells, with each cell at least one pixel.
+func gridCell(r image.Rectangle, x, y, w, h int) image.Rectangle {
+	x0, x1 := r.Min.X+x*r.Dx()/w, r.Min.X+(x+1)*r.Dx()/w
+	y0, y1 := r.Min.Y+y*r.Dy()/h, r.Min.Y+(y+1)*r.Dy()/h
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	return image.Rect(x0, y0, x1, y1)
+}
+
+// averageGray returns the average luma of the premultiplied pixels of img
+// within r.
+func averageGray(img *image.RGBA, r image.Rectangle) uint32 {
+	var sum, n uint32
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := img.Pix[img.PixOffset(x, y):]
+			sum += (19595*uint32(p[0]) + 38470*uint32(p[1]) + 7471*uint32(p[2]) + 1<<15) >> 16
+			n++
+		}
+	}
+	return sum / n
+}
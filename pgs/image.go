@@ -1,77 +1,301 @@
 package pgs
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 )
 
+// DataReader returns a reader of the object data, whether it is held in
+// Data or was spilled to a file by the Reader.
+func (obj *Object) DataReader() io.Reader {
+	if obj.Data == nil && obj.spill != nil {
+		return io.NewSectionReader(obj.spill, 0, obj.spill.Size())
+	}
+	return bytes.NewReader(obj.Data)
+}
+
+// LoadData reads object data spilled to a file by the Reader into Data,
+// so that the object can be decoded. It does nothing if the data is
+// already in memory.
+func (obj *Object) LoadData() error {
+	if obj.Data != nil || obj.spill == nil {
+		return nil
+	}
+	data := make([]byte, obj.spill.Size())
+	if _, err := obj.spill.ReadAt(data, 0); err != nil {
+		return err
+	}
+	obj.Data = data
+	return nil
+}
+
+// VerifyDecode decodes the object data without allocating an image and
+// checks that it yields exactly Width×Height pixels, with each line
+// terminated at the declared width, catching data inconsistent with the
+// declared dimensions, which renders garbled or truncated. Objects split
+// into fragments cannot be verified alone.
+func (obj *Object) VerifyDecode() error {
+	if !obj.First || !obj.Last {
+		return errors.New("object is a fragment of a sequence")
+	}
+	img := obj.Image
+	if img.Data == nil && obj.spill != nil {
+		data, err := io.ReadAll(obj.DataReader())
+		if err != nil {
+			return err
+		}
+		img.Data = data
+	} else if img.Data == nil && obj.DataLen != 0 {
+		return errors.New("object data was skipped when read")
+	}
+	var pixels int64
+	if err := img.decodeRuns(func(x, y, n int, c uint8) {
+		pixels += int64(n)
+	}); err != nil {
+		return err
+	}
+	if want := int64(img.Width) * int64(img.Height); pixels != want {
+		return fmt.Errorf("decoded %d pixels instead of %dx%d", pixels, img.Width, img.Height)
+	}
+	return nil
+}
+
+// Decode decodes the object into a paletted image of its dimensions with
+// the colors of the palette entries converted to RGBA. Unlike Convert,
+// it fails if a pixel uses an entry ID not defined in the palette. The
+// data must be complete, so fragments of a sequence are first joined
+// with JoinFragments.
+func (obj *Object) Decode(p *Palette) (*image.Paletted, error) {
+	if !obj.First || !obj.Last {
+		return nil, errors.New("object is a fragment of a sequence")
+	}
+	pimg, err := obj.Convert(p)
+	if err != nil {
+		return nil, err
+	}
+	var defined [256]bool
+	for _, e := range p.Entries {
+		defined[e.ID] = true
+	}
+	for _, c := range pimg.Pix {
+		if !defined[c] {
+			return nil, fmt.Errorf("palette entry %d used but not defined", c)
+		}
+	}
+	for _, e := range p.Entries {
+		pimg.Palette[e.ID] = color.RGBAModel.Convert(e.NYCbCrA)
+	}
+	return pimg, nil
+}
+
+// JoinFragments joins the fragments of an object split across object
+// definitions into one object by concatenating their data. The fragments
+// must be in order, from the first in the sequence to the last, and of
+// the same object ID and version. The dimensions are those declared by
+// the first fragment.
+func JoinFragments(frags []*Object) (*Object, error) {
+	if len(frags) == 0 {
+		return nil, errors.New("no fragments")
+	}
+	first, last := frags[0], frags[len(frags)-1]
+	if !first.First {
+		return nil, errors.New("first fragment not first in sequence")
+	}
+	if !last.Last {
+		return nil, errors.New("last fragment not last in sequence")
+	}
+	obj := *first
+	obj.Last = true
+	obj.Data, obj.spill = nil, nil
+	obj.DataLen = 0
+	for i, frag := range frags {
+		if frag.ID != first.ID || frag.Version != first.Version {
+			return nil, fmt.Errorf("fragment %d: object %d version %d, want object %d version %d",
+				i, frag.ID, frag.Version, first.ID, first.Version)
+		}
+		if i != 0 && frag.First || i != len(frags)-1 && frag.Last {
+			return nil, fmt.Errorf("fragment %d: sequence flags out of order", i)
+		}
+		data, err := io.ReadAll(frag.DataReader())
+		if err != nil {
+			return nil, fmt.Errorf("fragment %d: %w", i, err)
+		}
+		if len(data) == 0 && frag.DataLen != 0 {
+			return nil, fmt.Errorf("fragment %d: object data was skipped when read", i)
+		}
+		obj.Data = append(obj.Data, data...)
+		obj.DataLen += frag.DataLen
+	}
+	return &obj, nil
+}
+
+// Convert decodes the image into a paletted image, where each color
+// index is the ID of the palette entry. IDs not defined in the palette
+// are transparent.
 func (img *Image) Convert(p *Palette) (*image.Paletted, error) {
+	return img.convert(p, false, nil)
+}
+
+// ConvertNRGBA decodes the image as in Convert into a non-premultiplied
+// RGBA image, for formats like PNG that store color and alpha
+// separately.
+func (img *Image) ConvertNRGBA(p *Palette) (*image.NRGBA, error) {
+	pimg, err := img.Convert(p)
+	if err != nil {
+		return nil, err
+	}
+	colors := make([]color.NRGBA, len(pimg.Palette))
+	for i, c := range pimg.Palette {
+		colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	nimg := image.NewNRGBA(pimg.Rect)
+	for i, c := range pimg.Pix {
+		n := colors[c]
+		nimg.Pix[i*4], nimg.Pix[i*4+1], nimg.Pix[i*4+2], nimg.Pix[i*4+3] = n.R, n.G, n.B, n.A
+	}
+	return nimg, nil
+}
+
+const (
+	maxRun    = 1<<14 - 1 // Pixels in the longest run
+	maxPixels = 1 << 24   // Pixels in the largest image decoded
+)
+
+// convert converts the image as in Convert, adding the number of runs
+// decoded to runs, when not nil.
+func (img *Image) convert(p *Palette, lenient bool, runs *int64) (*image.Paletted, error) {
+	// Each line ends with at least two bytes, so reject data too short
+	// for the height before allocating the image. Lenient lines may lack
+	// the end of line marker, but still take at least one byte.
+	if int(img.Height) > len(img.Data)/2 && !lenient || int(img.Height) > len(img.Data) {
+		return nil, fmt.Errorf("%d bytes of data too short for %d lines", len(img.Data), img.Height)
+	}
+	// Likewise, each run covers at most 16383 pixels in at least three
+	// bytes, when longer than 63 pixels. Lenient lines may be short, so
+	// images are also bounded to a size well beyond any video frame.
+	pixels := int(img.Width) * int(img.Height)
+	if pixels > (len(img.Data)/3+1)*maxRun && !lenient {
+		return nil, fmt.Errorf("%d bytes of data too short for %dx%d image", len(img.Data), img.Width, img.Height)
+	}
+	if pixels > maxPixels {
+		return nil, fmt.Errorf("image dimensions %dx%d exceed %d pixels", img.Width, img.Height, maxPixels)
+	}
 	var cp color.Palette
-	ids := make([]uint8, len(p.Entries))
-	idMap := make(map[uint8]uint8)
-	for i, e := range p.Entries {
-		ids[i] = e.ID
-		idMap[e.ID] = uint8(i)
-		cp = append(cp, e.NYCbCrA)
+	for _, e := range p.Entries {
+		for len(cp) <= int(e.ID) {
+			cp = append(cp, color.Transparent)
+		}
+		cp[e.ID] = e.NYCbCrA
 	}
 	rect := image.Rectangle{Max: image.Point{int(img.Width), int(img.Height)}}
 	pimg := image.NewPaletted(rect, cp)
 
-	d := img.Data
-	x, y := 0, 0
-	for i := 0; i < len(d); {
-		if d[i] != 0 { // CCCCCCCC - One pixel in color C
-			pimg.SetColorIndex(x, y, d[i])
-			i++
-			x++
-			continue
-		}
-		var c uint8
-		var l uint16
-
-		hd1, ld1 := d[i+1]&0xc0, d[i+1]&0x3f
-		switch hd1 {
-		case 0x00:
-			i += 2
-			// 00000000 00000000 - End of line
-			if ld1 == 0 {
-				if x != int(img.Width) {
-					return nil, fmt.Errorf("line %d has width %d instead of %d", y, x, img.Width)
-				}
-				x = 0
-				y++
-				continue
-			}
-			// 00000000 00LLLLLL - L pixels in color 0
-			l = uint16(ld1)
-		// 00000000 01LLLLLL LLLLLLLL - L pixels in color 0
-		case 0x40:
-			l = uint16(ld1)<<8 | uint16(d[i+2])
-			i += 3
-		// 00000000 10LLLLLL CCCCCCCC - L pixels in color C
-		case 0x80:
-			l = uint16(ld1)
-			c = idMap[d[i+2]]
-			i += 3
-		// 00000000 11LLLLLL LLLLLLLL CCCCCCCC - L pixels in color C
-		case 0xc0:
-			l = uint16(ld1)<<8 | uint16(d[i+2])
-			c = idMap[d[i+3]]
-			i += 4
-		default:
-			panic("impossible")
-		}
-		for i := 0; i < int(l); i++ {
+	maxID := -1
+	err := img.walkRuns(lenient, func(x, y, n int, c uint8) {
+		if runs != nil {
+			*runs++
+		}
+		for i := 0; i < n; i++ {
 			pimg.SetColorIndex(x+i, y, c)
 		}
-		x += int(l)
-	}
-	if x != 0 {
-		return nil, fmt.Errorf("line %d with width %d not terminated", y, x)
+		if n > 0 && int(c) > maxID {
+			maxID = int(c)
+		}
+	})
+	if err != nil {
+		return nil, err
 	}
-	if y != int(img.Height) {
-		return nil, fmt.Errorf("image has height %d instead of %d", y, img.Height)
+	for len(pimg.Palette) <= maxID {
+		pimg.Palette = append(pimg.Palette, color.Transparent)
 	}
 	return pimg, nil
 }
+
+// bounds returns the smallest rectangle containing all pixels that are
+// not fully transparent in the palette. Entries missing from the palette
+// are treated as transparent.
+func (img *Image) bounds(p *Palette) (image.Rectangle, error) {
+	var opaque [256]bool
+	for _, e := range p.Entries {
+		opaque[e.ID] = e.A != 0
+	}
+	var r image.Rectangle
+	err := img.decodeRuns(func(x, y, n int, c uint8) {
+		if opaque[c] && n > 0 {
+			r = r.Union(image.Rect(x, y, x+n, y+1))
+		}
+	})
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return r.Intersect(image.Rect(0, 0, int(img.Width), int(img.Height))), nil
+}
+
+// UsedIndices decodes the image and returns the number of pixels of each
+// palette entry ID used.
+func (img *Image) UsedIndices() (map[uint8]int, error) {
+	used := make(map[uint8]int)
+	err := img.decodeRuns(func(x, y, n int, c uint8) {
+		if n > 0 {
+			used[c] += n
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return used, nil
+}
+
+// DecodedSize returns the number of pixels in the decoded object, which
+// is the buffer size needed by DecodeInto.
+func (obj *Object) DecodedSize() int {
+	return int(obj.Width) * int(obj.Height)
+}
+
+// DecodeInto decodes the object into dst, in rows of Width pixels, where
+// each pixel is the ID of its palette entry. It avoids allocating for
+// callers reusing a buffer across objects. As with Decode, it fails if a
+// pixel uses an entry ID not defined in p, unless p is nil, and the data
+// must be complete.
+func (obj *Object) DecodeInto(dst []uint8, p *Palette) error {
+	if !obj.First || !obj.Last {
+		return errors.New("object is a fragment of a sequence")
+	}
+	if len(dst) < obj.DecodedSize() {
+		return fmt.Errorf("buffer of %d pixels too small for %dx%d image", len(dst), obj.Width, obj.Height)
+	}
+	var defined [256]bool
+	if p != nil {
+		for _, e := range p.Entries {
+			defined[e.ID] = true
+		}
+	}
+	w := int(obj.Width)
+	undefined := -1
+	err := obj.decodeRuns(func(x, y, n int, c uint8) {
+		if x+n > w {
+			n = w - x
+		}
+		if y >= int(obj.Height) || n <= 0 {
+			return
+		}
+		if p != nil && !defined[c] && undefined < 0 {
+			undefined = int(c)
+		}
+		row := dst[y*w+x : y*w+x+n]
+		for i := range row {
+			row[i] = c
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if undefined >= 0 {
+		return fmt.Errorf("palette entry %d used but not defined", undefined)
+	}
+	return nil
+}
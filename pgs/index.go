@@ -0,0 +1,204 @@
+package pgs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Index records the location of each display set in a stream for random
+// access.
+type Index struct {
+	Entries []IndexEntry
+}
+
+// IndexEntry locates a display set in a stream.
+type IndexEntry struct {
+	Offset            int64 // Byte offset of the PCS in the stream
+	PresentationTime  time.Duration
+	DecodingTime      time.Duration
+	CompositionNumber uint16
+	CompositionState  CompositionState
+	// Byte offset of the PCS starting the epoch of the display set, from
+	// which its palettes and objects can be replayed
+	EpochOffset int64
+}
+
+// BuildIndex reads the stream from its current position, without reading
+// object data, and records the location of each display set.
+func BuildIndex(r io.ReadSeeker) (*Index, error) {
+	base, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	pr := NewReader(r)
+	pr.SkipObjectData(true)
+	var idx Index
+	epoch := base
+	for {
+		off := pr.r.n
+		ds, err := pr.Read()
+		if err == io.EOF {
+			return &idx, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("display set %d at offset %d: %w", len(idx.Entries), base+off, err)
+		}
+		// Display sets before the first EpochStart form their own epoch,
+		// as with EpochReader
+		if ds.CompositionState == EpochStart {
+			epoch = base + off
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Offset:            base + off,
+			PresentationTime:  ds.PresentationTime,
+			DecodingTime:      ds.DecodingTime,
+			CompositionNumber: ds.CompositionNumber,
+			CompositionState:  ds.CompositionState,
+			EpochOffset:       epoch,
+		})
+	}
+}
+
+// DisplaySetAt seeks to and reads the ith display set in the index.
+func (idx *Index) DisplaySetAt(i int, r io.ReadSeeker) (*DisplaySet, error) {
+	if i < 0 || i >= len(idx.Entries) {
+		return nil, fmt.Errorf("display set %d out of range [0, %d)", i, len(idx.Entries))
+	}
+	e := &idx.Entries[i]
+	if _, err := r.Seek(e.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	ds, err := NewReader(r).Read()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Use the times from the index, which account for wraparounds of the
+	// clock before this display set
+	ds.PresentationTime, ds.DecodingTime = e.PresentationTime, e.DecodingTime
+	return ds, nil
+}
+
+// Find returns the index of the last display set presented at or before
+// t, or -1 if there is none. Entries must be in presentation order.
+func (idx *Index) Find(t time.Duration) int {
+	return sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].PresentationTime > t
+	}) - 1
+}
+
+// Seek positions r at the last display set presented at or before t.
+// That display set may refer to palettes and objects defined earlier in
+// its epoch; use ResolveAt to render the moment.
+func (idx *Index) Seek(r io.ReadSeeker, t time.Duration) error {
+	i := idx.Find(t)
+	if i < 0 {
+		return fmt.Errorf("no display set at or before %s", t)
+	}
+	_, err := r.Seek(idx.Entries[i].Offset, io.SeekStart)
+	return err
+}
+
+// Resolve replays the epoch of the ith display set in the index, reading
+// from the start of the epoch, and returns the display set resolved with
+// the palette and object it uses, as with Epoch.Resolve.
+func (idx *Index) Resolve(r io.ReadSeeker, i int) (*DisplaySet, error) {
+	if i < 0 || i >= len(idx.Entries) {
+		return nil, fmt.Errorf("display set %d out of range [0, %d)", i, len(idx.Entries))
+	}
+	e, err := idx.replay(r, i)
+	if err != nil {
+		return nil, err
+	}
+	return e.Resolve(len(e.DisplaySets) - 1)
+}
+
+// ResolveAt replays the epoch of the display set on screen at t, as with
+// Resolve, and returns the display set resolved as with Epoch.At, or nil
+// if nothing is shown at t.
+func (idx *Index) ResolveAt(r io.ReadSeeker, t time.Duration) (*DisplaySet, error) {
+	i := idx.Find(t)
+	if i < 0 {
+		return nil, nil
+	}
+	e, err := idx.replay(r, i)
+	if err != nil {
+		return nil, err
+	}
+	return e.At(t)
+}
+
+// replay reads the display sets of the epoch of the ith display set up to
+// it.
+func (idx *Index) replay(r io.ReadSeeker, i int) (*Epoch, error) {
+	start := i
+	for start > 0 && idx.Entries[start].Offset != idx.Entries[i].EpochOffset {
+		start--
+	}
+	if _, err := r.Seek(idx.Entries[start].Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	pr := NewReader(r)
+	var e Epoch
+	for j := start; j <= i; j++ {
+		ds, err := pr.Read()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("display set %d: %w", j, err)
+		}
+		ds.PresentationTime, ds.DecodingTime = idx.Entries[j].PresentationTime, idx.Entries[j].DecodingTime
+		e.DisplaySets = append(e.DisplaySets, *ds)
+	}
+	return &e, nil
+}
+
+// The serialized index begins with indexMagic and the number of entries
+// as a uint32, followed by the fields of each entry in big-endian order.
+const indexMagic = "PGSINDEX1"
+
+// WriteIndex serializes the index, to be reused with ReadIndex.
+func (idx *Index) WriteIndex(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(indexMagic)
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(idx.Entries))); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, idx.Entries); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ReadIndex reads an index serialized by WriteIndex.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != indexMagic {
+		return nil, errors.New("not a PGS index")
+	}
+	var n uint32
+	if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	var idx Index
+	for ; n > 0; n-- {
+		var e IndexEntry
+		if err := binary.Read(br, binary.BigEndian, &e); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("index entry %d: %w", len(idx.Entries), err)
+		}
+		idx.Entries = append(idx.Entries, e)
+	}
+	return &idx, nil
+}
@@ -0,0 +1,111 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestIndexSeek(t *testing.T) {
+	var stream []DisplaySet
+	add := func(ds *DisplaySet) {
+		ds.Width, ds.Height = 4, 4
+		ds.CompositionNumber = uint16(len(stream))
+		stream = append(stream, *ds)
+	}
+	for i, c := range []color.Color{color.White, color.Black} {
+		img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Transparent, c})
+		img.SetColorIndex(i, i, 1)
+		start := time.Duration(3*i+1) * time.Second
+		show, err := NewDisplaySet(img, 1, 1, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(show)
+		// Move the object without redefining it, so that it can only be
+		// rendered by replaying the epoch
+		move := NewClearDisplaySet(start+time.Second, 0)
+		move.Objects = []CompositionObject{{X: 2, Y: 2}}
+		add(move)
+		add(NewClearDisplaySet(start+2*time.Second, 0))
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(b.Bytes())
+	idx, err := BuildIndex(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != len(stream) {
+		t.Fatalf("got %d entries, want %d", len(idx.Entries), len(stream))
+	}
+	if e := idx.Entries[4]; e.EpochOffset != idx.Entries[3].Offset || e.CompositionNumber != 4 {
+		t.Errorf("entry 4 has epoch offset %d and composition number %d, want %d and 4",
+			e.EpochOffset, e.CompositionNumber, idx.Entries[3].Offset)
+	}
+
+	for _, tc := range []struct {
+		t    time.Duration
+		want int
+	}{{0, -1}, {time.Second, 0}, {4500 * time.Millisecond, 3}, {time.Hour, 5}} {
+		if got := idx.Find(tc.t); got != tc.want {
+			t.Errorf("Find(%s) = %d, want %d", tc.t, got, tc.want)
+		}
+	}
+	if err := idx.Seek(r, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := NewReader(r).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.CompositionNumber != 4 {
+		t.Errorf("seeked to display set %d, want 4", ds.CompositionNumber)
+	}
+	if err := idx.Seek(r, 0); err == nil {
+		t.Error("seeked before the first display set")
+	}
+
+	ds, err = idx.ResolveAt(r, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds == nil || ds.Object == nil || ds.Palette == nil {
+		t.Fatal("display set at 5s not resolved")
+	}
+	img, err := ds.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(3, 3).RGBA(); a == 0 {
+		t.Error("moved object not rendered")
+	}
+	if ds, err := idx.ResolveAt(r, 6*time.Second); err != nil || ds != nil {
+		t.Errorf("ResolveAt cleared screen = %v, %v, want nil", ds, err)
+	}
+
+	var ser bytes.Buffer
+	if err := idx.WriteIndex(&ser); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadIndex(bytes.NewReader(ser.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Entries) != len(idx.Entries) {
+		t.Fatalf("read %d entries, want %d", len(got.Entries), len(idx.Entries))
+	}
+	for i := range got.Entries {
+		if got.Entries[i] != idx.Entries[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i, got.Entries[i], idx.Entries[i])
+		}
+	}
+	if _, err := ReadIndex(io.LimitReader(bytes.NewReader(ser.Bytes()), int64(ser.Len()-1))); err == nil {
+		t.Error("read truncated index")
+	}
+}
@@ -0,0 +1,172 @@
+package pgs
+
+import (
+	"io"
+	"iter"
+	"math"
+	"time"
+)
+
+// Interval is a span of time during which a display set is shown.
+type Interval struct {
+	Start, End time.Duration
+	DisplaySet *DisplaySet
+}
+
+// Duration returns the length of the interval.
+func (i Interval) Duration() time.Duration {
+	return i.End - i.Start
+}
+
+// IsClear reports whether the display set clears the screen, that is,
+// it has no composition objects.
+func (ds *DisplaySet) IsClear() bool {
+	return len(ds.Objects) == 0
+}
+
+// CharsPerSecond returns the reading speed of text shown over the
+// interval, counting characters other than line breaks. Text shown for
+// no time has an infinite speed.
+func (i Interval) CharsPerSecond(text string) float64 {
+	n := 0
+	for _, r := range text {
+		if r != '\n' && r != '\r' {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	if i.Duration() <= 0 {
+		return math.Inf(1)
+	}
+	return float64(n) / i.Duration().Seconds()
+}
+
+// Intervals reads the stream and returns the intervals during which each
+// display set with composition objects is shown. A display set is shown
+// until the next display set replaces or clears it. Palette updates, as
+// in fades, continue the interval of the shown display set rather than
+// replacing it. A display set that is still shown at the end of the
+// stream ends at the presentation time of the last display set.
+func Intervals(r *Reader) ([]Interval, error) {
+	var intervals []Interval
+	for iv, err := range r.Intervals() {
+		if err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, iv)
+	}
+	return intervals, nil
+}
+
+// Intervals returns an iterator over the intervals of the stream, as
+// returned by the Intervals function. Each interval is yielded as soon
+// as the display set ending it is read, so the stream is not buffered.
+// Iteration stops after yielding an error.
+func (r *Reader) Intervals() iter.Seq2[Interval, error] {
+	return func(yield func(Interval, error) bool) {
+		var open *Interval
+		var end time.Duration
+		for {
+			ds, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				yield(Interval{}, err)
+				return
+			}
+			end = ds.PresentationTime
+			if open != nil && ds.PaletteUpdate && !ds.IsClear() {
+				continue
+			}
+			if open != nil {
+				open.End = ds.PresentationTime
+				if !yield(*open, nil) {
+					return
+				}
+				open = nil
+			}
+			if !ds.IsClear() {
+				open = &Interval{Start: ds.PresentationTime, DisplaySet: ds}
+			}
+		}
+		if open != nil {
+			open.End = end
+			yield(*open, nil)
+		}
+	}
+}
+
+// MinDurationViolations reads the stream and returns the intervals that
+// are shown for less than min.
+func MinDurationViolations(r *Reader, min time.Duration) ([]Interval, error) {
+	intervals, err := Intervals(r)
+	if err != nil {
+		return nil, err
+	}
+	var short []Interval
+	for _, i := range intervals {
+		if i.Duration() < min {
+			short = append(short, i)
+		}
+	}
+	return short, nil
+}
+
+// ActiveDisplaySet reads the stream up to t and returns the display set
+// on screen at t, the most recent one with composition objects that has
+// not been cleared or replaced. Palette updates continue the shown
+// display set, as with Intervals, so the display set that began the
+// event is returned. It returns nil if nothing is shown at t.
+func ActiveDisplaySet(r *Reader, t time.Duration) (*DisplaySet, error) {
+	var shown *DisplaySet
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return shown, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.PresentationTime > t {
+			return shown, nil
+		}
+		if shown != nil && ds.PaletteUpdate && !ds.IsClear() {
+			continue
+		}
+		shown = ds
+		if ds.IsClear() {
+			shown = nil
+		}
+	}
+}
+
+// Gap is a span of time during which no subtitle is shown.
+type Gap struct {
+	Start, End time.Duration
+}
+
+// Duration returns the length of the gap.
+func (g Gap) Duration() time.Duration {
+	return g.End - g.Start
+}
+
+// Gaps reads the stream and returns the gaps between the clear of each
+// subtitle and the next subtitle shown. Gaps of under two frames suggest
+// subtitles that effectively touch and may flicker.
+func Gaps(r *Reader) ([]Gap, error) {
+	var gaps []Gap
+	var prev *Interval
+	for iv, err := range r.Intervals() {
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && iv.Start > prev.End {
+			gaps = append(gaps, Gap{prev.End, iv.Start})
+		}
+		prev = &iv
+	}
+	return gaps, nil
+}
@@ -0,0 +1,64 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteObjectPNGs reads the stream and writes each object defined in it
+// to its own PNG file in dir, named by epoch, object ID, and version, as
+// in obj_0_1_v0.png. Objects are drawn with the active palette, without
+// compositing or cropping, and objects with no visible pixels are
+// skipped. The palette active for an object is the latest definition in
+// the epoch of the palette referenced by its display set.
+func WriteObjectPNGs(r *Reader, dir string) error {
+	epoch := -1
+	var palettes PaletteHistory
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ds.CompositionState == EpochStart || epoch < 0 {
+			epoch++
+		}
+		palettes.Update(ds)
+		if ds.Object == nil {
+			continue
+		}
+		name := fmt.Sprintf("obj_%d_%d_v%d.png", epoch, ds.Object.ID, ds.Object.Version)
+		if err := writeObjectPNG(filepath.Join(dir, name), ds.Object, palettes.Active(ds)); err != nil {
+			return fmt.Errorf("display set %d: object %d: %w", i, ds.Object.ID, err)
+		}
+	}
+}
+
+func writeObjectPNG(filename string, obj *Object, p *Palette) error {
+	if p == nil {
+		return errors.New("no palette active")
+	}
+	bounds, err := obj.bounds(p)
+	if err != nil || bounds.Empty() {
+		return err
+	}
+	img, err := obj.Convert(p)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return f.Close()
+}
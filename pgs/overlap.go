@@ -0,0 +1,68 @@
+package pgs
+
+import (
+	"io"
+	"time"
+)
+
+// OverlapReport describes a display set shown before the previous one
+// was cleared.
+type OverlapReport struct {
+	Time        time.Duration // Presentation time of the later display set
+	Previous    *DisplaySet
+	DisplaySet  *DisplaySet
+	Intentional bool // Whether the later display set adds a window
+}
+
+// TemporalOverlaps reads the stream and reports each display set with
+// composition objects that follows another without a clear between
+// them. An overlap is considered intentional when the later composition
+// places an object in a window that the previous one did not use, as
+// when a second subtitle is shown alongside the first. Otherwise, the
+// previous subtitle is replaced without being cleared, which is usually
+// a defect in authored tracks. Palette updates, which change the colors
+// of the shown subtitle, are not reported.
+func TemporalOverlaps(r *Reader) ([]OverlapReport, error) {
+	var overlaps []OverlapReport
+	var shown *DisplaySet
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return overlaps, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.IsClear() {
+			shown = nil
+			continue
+		}
+		if ds.PaletteUpdate {
+			continue
+		}
+		if shown != nil {
+			overlaps = append(overlaps, OverlapReport{
+				Time:        ds.PresentationTime,
+				Previous:    shown,
+				DisplaySet:  ds,
+				Intentional: addsWindow(shown, ds),
+			})
+		}
+		shown = ds
+	}
+}
+
+// addsWindow reports whether next has a composition object in a window
+// not used by prev.
+func addsWindow(prev, next *DisplaySet) bool {
+	var used [256]bool
+	for _, co := range prev.Objects {
+		used[co.WindowID] = true
+	}
+	for _, co := range next.Objects {
+		if !used[co.WindowID] {
+			return true
+		}
+	}
+	return false
+}
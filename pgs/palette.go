@@ -0,0 +1,148 @@
+package pgs
+
+import (
+	"image/color"
+	"io"
+	"math"
+)
+
+// PaletteEntryChange is a difference in an entry between two versions of
+// a palette. Old is nil for an added entry and New is nil for a removed
+// entry.
+type PaletteEntryChange struct {
+	ID       uint8
+	Old, New *PaletteEntry
+}
+
+// PaletteDiff returns the entries added, removed, or modified from old to
+// new, in order of ID.
+func PaletteDiff(old, new *Palette) []PaletteEntryChange {
+	var olds, news [256]*PaletteEntry
+	for i := range old.Entries {
+		olds[old.Entries[i].ID] = &old.Entries[i]
+	}
+	for i := range new.Entries {
+		news[new.Entries[i].ID] = &new.Entries[i]
+	}
+	var changes []PaletteEntryChange
+	for id := range olds {
+		o, n := olds[id], news[id]
+		if o == nil && n == nil || o != nil && n != nil && *o == *n {
+			continue
+		}
+		changes = append(changes, PaletteEntryChange{uint8(id), o, n})
+	}
+	return changes
+}
+
+// WriteACT writes the palette as a Photoshop color table of 256 RGB
+// triples, with each entry at the index of its ID. Alpha is discarded
+// and undefined entries are black.
+func (p *Palette) WriteACT(w io.Writer) error {
+	var act [256 * 3]byte
+	for _, e := range p.Entries {
+		r, g, b := color.YCbCrToRGB(e.Y, e.Cb, e.Cr)
+		act[int(e.ID)*3], act[int(e.ID)*3+1], act[int(e.ID)*3+2] = r, g, b
+	}
+	_, err := w.Write(act[:])
+	return err
+}
+
+// EnsureMinOpacity returns a copy of the palette with each alpha below
+// min raised to min, except for fully transparent entries, which are
+// transparent by design and left unchanged, to rescue faint subtitles.
+func (p *Palette) EnsureMinOpacity(min uint8) *Palette {
+	q := *p
+	q.Entries = make([]PaletteEntry, len(p.Entries))
+	for i, e := range p.Entries {
+		if e.A != 0 && e.A < min {
+			e.A = min
+		}
+		q.Entries[i] = e
+	}
+	return &q
+}
+
+// PaletteAdjustment is a change to the colors of palette entries, for
+// subtitles rendered too dim or in an unwanted tint.
+type PaletteAdjustment struct {
+	Gain     float64 // Factor scaling luma above black; 1 keeps it
+	Offset   float64 // Added to luma after Gain
+	Cb, Cr   int     // Added to chroma
+	MinAlpha uint8   // Alpha floor, as with EnsureMinOpacity
+}
+
+// Adjust returns a copy of the palette with the adjustment applied to
+// each entry. Luma and chroma are clamped to the video range of 16-235
+// and 16-240, in which palettes are encoded. Fully transparent entries
+// are left unchanged.
+func (p *Palette) Adjust(a PaletteAdjustment) *Palette {
+	q := *p
+	q.Entries = make([]PaletteEntry, len(p.Entries))
+	for i, e := range p.Entries {
+		if e.A != 0 {
+			y := (float64(e.Y)-16)*a.Gain + 16 + a.Offset
+			e.Y = uint8(min(max(math.Round(y), 16), 235))
+			e.Cb = uint8(min(max(int(e.Cb)+a.Cb, 16), 240))
+			e.Cr = uint8(min(max(int(e.Cr)+a.Cr, 16), 240))
+			e.A = max(e.A, a.MinAlpha)
+		}
+		q.Entries[i] = e
+	}
+	return &q
+}
+
+// PaletteHistory tracks the latest definition of each palette ID in the
+// current epoch, for resolving the palette used by a display set that
+// does not define it, such as one of the palette updates of a fade.
+type PaletteHistory struct {
+	palettes map[uint8]*Palette
+}
+
+// Update records the palettes defined by the display set, first
+// forgetting the palettes of the previous epoch at an EpochStart.
+func (h *PaletteHistory) Update(ds *DisplaySet) {
+	if h.palettes == nil || ds.CompositionState == EpochStart {
+		h.palettes = make(map[uint8]*Palette)
+	}
+	for _, p := range ds.DefinedPalettes() {
+		h.palettes[p.ID] = p
+	}
+}
+
+// Active returns the palette used by the display set, which is the
+// latest definition of its PaletteID recorded, or nil when there is
+// none. The display set should be recorded with Update first.
+func (h *PaletteHistory) Active(ds *DisplaySet) *Palette {
+	return h.palettes[ds.PaletteID]
+}
+
+// DefinesActivePalette reports whether a palette defined by the display
+// set is the one it uses. A palette update may define a palette other
+// than the one it uses, which must not be applied to the shown objects.
+func (ds *DisplaySet) DefinesActivePalette() bool {
+	for _, p := range ds.DefinedPalettes() {
+		if p.ID == ds.PaletteID {
+			return true
+		}
+	}
+	return false
+}
+
+// GrayscalePalette returns a palette for RenderFallback that maps entry
+// 0, commonly the background, to transparent and the other entry IDs to
+// an opaque ramp from white for 1, commonly the text fill, to black for
+// 255. Distinct IDs have distinct shades, so the shapes of objects are
+// legible whatever their original colors.
+func GrayscalePalette() *Palette {
+	p := &Palette{Entries: make([]PaletteEntry, 256)}
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		e.ID = uint8(i)
+		e.Cb, e.Cr = 128, 128
+		if i != 0 {
+			e.Y, e.A = uint8(235-(i-1)*219/254), 0xff
+		}
+	}
+	return p
+}
@@ -3,6 +3,7 @@ package pgs
 import (
 	"fmt"
 	"image/color"
+	"io"
 	"time"
 )
 
@@ -11,17 +12,60 @@ type DisplaySet struct {
 	DecodingTime     time.Duration
 	PresentationComposition
 	Windows []Window
-	Palette *Palette
-	Object  *Object
+	Palette *Palette // First palette defined, not necessarily the one used; see PaletteID
+	Object  *Object  // First object defined
+	// Palettes and objects defined after Palette and Object, in stream
+	// order, for display sets defining more than one, such as an object
+	// for each line of a subtitle; see DefinedPalettes and DefinedObjects
+	ExtraPalettes []Palette
+	ExtraObjects  []Object
+	Headers       []Header // Raw segment headers, when kept by the Reader
+	Payloads      [][]byte // Raw segment payloads, when kept by the Reader
+	Vendor        []VendorSegment
+	prior         *epochDefs // Definitions earlier in the epoch, when read by a Reader
+}
+
+// epochDefs is the last definition of each palette and object ID in an
+// epoch.
+type epochDefs struct {
+	palettes map[uint8]*Palette
+	objects  map[uint16]*Object
+}
+
+// with returns the definitions with those of the display set added,
+// copying them so that the definitions linked to earlier display sets
+// are unchanged.
+func (d *epochDefs) with(ds *DisplaySet) *epochDefs {
+	palettes, objects := ds.DefinedPalettes(), ds.DefinedObjects()
+	if len(palettes) == 0 && len(objects) == 0 {
+		return d
+	}
+	defs := &epochDefs{
+		palettes: make(map[uint8]*Palette, len(d.palettes)+len(palettes)),
+		objects:  make(map[uint16]*Object, len(d.objects)+len(objects)),
+	}
+	for id, p := range d.palettes {
+		defs.palettes[id] = p
+	}
+	for id, obj := range d.objects {
+		defs.objects[id] = obj
+	}
+	for _, p := range palettes {
+		defs.palettes[p.ID] = p
+	}
+	for _, obj := range objects {
+		defs.objects[obj.ID] = obj
+	}
+	return defs
 }
 
 type PresentationComposition struct {
-	Width, Height     uint16 // Video dimensions in pixels
-	FrameRate         uint8  // Always 0x10; can be ignored
+	Width, Height     uint16    // Video dimensions in pixels
+	FrameRate         FrameRate // Usually 0x10
 	CompositionNumber uint16
 	CompositionState  CompositionState // Type of this composition
 	PaletteUpdate     bool
-	PaletteID         uint8
+	PaletteID         uint8 // Palette used, and the target of palette updates
 	Objects           []CompositionObject
 }
 
@@ -29,6 +73,7 @@ type CompositionObject struct {
 	ObjectID uint16
 	WindowID uint8
 	X, Y     uint16 // Offset from the top left pixel of the screen
+	Forced   bool   // Shown even when subtitles are off, such as for foreign dialogue
 	Crop     *CompositionObjectCrop
 }
 
@@ -59,7 +104,12 @@ type Object struct {
 	ID          uint16 // ID of this object
 	Version     uint8  // Version of this object
 	First, Last bool
+	DataLen     int // Length of Data, set even when data is skipped
 	Image
+	spill *io.SectionReader // Data spilled to a file by the Reader
+	// Data length of each segment of the object, as read, so that the
+	// Writer can split unchanged data the same way
+	fragments []int
 }
 
 type Image struct {
@@ -67,6 +117,19 @@ type Image struct {
 	Data          []byte
 }
 
+// FrameRate is the frame rate code of the video, stored in the upper
+// four bits.
+type FrameRate uint8
+
+const (
+	FrameRate23976 FrameRate = 0x10 // 24000/1001, also used when unknown
+	FrameRate24    FrameRate = 0x20
+	FrameRate25    FrameRate = 0x30
+	FrameRate2997  FrameRate = 0x40 // 30000/1001
+	FrameRate50    FrameRate = 0x60
+	FrameRate5994  FrameRate = 0x70 // 60000/1001
+)
+
 type SegmentType uint8
 
 const (
@@ -113,7 +176,54 @@ func (typ SegmentType) String() string {
 	case ENDType:
 		return "END"
 	}
-	return fmt.Sprintf("%x", string(typ))
+	return fmt.Sprintf("0x%02x", uint8(typ))
+}
+
+// FPS returns the frames per second of the frame rate code, or 0 if the
+// code is not recognized.
+func (f FrameRate) FPS() float64 {
+	switch f {
+	case FrameRate23976:
+		return 24000.0 / 1001
+	case FrameRate24:
+		return 24
+	case FrameRate25:
+		return 25
+	case FrameRate2997:
+		return 30000.0 / 1001
+	case FrameRate50:
+		return 50
+	case FrameRate5994:
+		return 60000.0 / 1001
+	}
+	return 0
+}
+
+// Interlaced reports whether the frame rate is of an interlaced video
+// format. BluRay only allows 25 and 29.97 fps in the 1080i, 576i, and
+// 480i formats, so those are interlaced, with two fields per frame. The
+// film rates of 23.976 and 24 fps and the 720p rates of 50 and 59.94 fps
+// are progressive.
+func (f FrameRate) Interlaced() bool {
+	return f == FrameRate25 || f == FrameRate2997
+}
+
+func (f FrameRate) String() string {
+	switch f {
+	case FrameRate23976:
+		return "23.976"
+	case FrameRate24:
+		return "24"
+	case FrameRate25:
+		return "25i"
+	case FrameRate2997:
+		return "29.97i"
+	case FrameRate50:
+		return "50"
+	case FrameRate5994:
+		return "59.94"
+	}
+	return fmt.Sprintf("FrameRate(0x%x)", uint8(f))
 }
 
 func (p *Palette) String() string {
@@ -0,0 +1,126 @@
+// Package pgstest builds synthetic PGS streams for tests.
+package pgstest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+type config struct {
+	epochs, subtitles     int
+	reuse                 bool
+	width, height         int
+	objWidth, objHeight   int
+	start, duration, step time.Duration
+}
+
+// Option configures a stream built by BuildStream.
+type Option func(*config)
+
+// Epochs sets the number of epochs. The default is 1.
+func Epochs(n int) Option {
+	return func(c *config) { c.epochs = n }
+}
+
+// Subtitles sets the number of subtitles shown in each epoch, each by a
+// display set followed by a clear. The default is 1.
+func Subtitles(n int) Option {
+	return func(c *config) { c.subtitles = n }
+}
+
+// ReuseObjects makes the later subtitles of each epoch show the object
+// and palette defined by its first subtitle, rather than defining their
+// own, as in streams that repeat a line.
+func ReuseObjects() Option {
+	return func(c *config) { c.reuse = true }
+}
+
+// FrameSize sets the video dimensions. The default is 1920x1080.
+func FrameSize(width, height int) Option {
+	return func(c *config) { c.width, c.height = width, height }
+}
+
+// ObjectSize sets the dimensions of each object. The default is 200x50.
+func ObjectSize(width, height int) Option {
+	return func(c *config) { c.objWidth, c.objHeight = width, height }
+}
+
+// Timing sets the presentation time of the first subtitle, how long each
+// subtitle is shown, and the time from the start of one subtitle to the
+// next. The default is 1s, 2s, and 3s.
+func Timing(start, duration, step time.Duration) Option {
+	return func(c *config) { c.start, c.duration, c.step = start, duration, step }
+}
+
+// BuildStream returns a valid PGS stream of subtitles centered at the
+// bottom of the frame. The first subtitle of each epoch starts it, and
+// each subtitle defines a distinct object, unless reused, so streams are
+// deterministic for given options. It panics if the options do not produce a valid
+// stream.
+func BuildStream(opts ...Option) []byte {
+	c := config{
+		epochs: 1, subtitles: 1,
+		width: 1920, height: 1080,
+		objWidth: 200, objHeight: 50,
+		start: time.Second, duration: 2 * time.Second, step: 3 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	var b bytes.Buffer
+	w := pgs.NewWriter(&b)
+	n, num := 0, 0
+	t := c.start
+	for e := 0; e < c.epochs; e++ {
+		for s := 0; s < c.subtitles; s++ {
+			img := objectImage(c.objWidth, c.objHeight, n)
+			show, err := pgs.NewDisplaySet(img, (c.width-c.objWidth)/2, c.height-c.objHeight-c.height/20, t)
+			if err != nil {
+				panic(err)
+			}
+			if s != 0 {
+				show.CompositionState = pgs.Normal
+				show.Object.Version = uint8(s)
+				show.Palette.Version = uint8(s)
+				if c.reuse {
+					show.Object, show.Palette = nil, nil
+				}
+			}
+			clear := pgs.NewClearDisplaySet(t+c.duration, 0)
+			for _, ds := range []*pgs.DisplaySet{show, clear} {
+				ds.Width, ds.Height = uint16(c.width), uint16(c.height)
+				ds.FrameRate = pgs.FrameRate23976
+				ds.CompositionNumber = uint16(num)
+				num++
+				if err := w.Write(ds); err != nil {
+					panic(err)
+				}
+			}
+			n++
+			t += c.step
+		}
+	}
+	return b.Bytes()
+}
+
+// objectImage returns a bordered image with a pattern distinct for each
+// index.
+func objectImage(width, height, index int) *image.Paletted {
+	p := color.Palette{color.Transparent, color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, width, height), p)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch {
+			case x == 0 || y == 0 || x == width-1 || y == height-1:
+				img.SetColorIndex(x, y, 2)
+			case (x+y+index)%(index+2) == 0:
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,49 @@
+package pgstest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+func TestBuildStream(t *testing.T) {
+	b := BuildStream(Epochs(2), Subtitles(3), FrameSize(720, 480), ObjectSize(40, 10),
+		Timing(0, time.Second, 2*time.Second))
+	if !bytes.Equal(b, BuildStream(Epochs(2), Subtitles(3), FrameSize(720, 480), ObjectSize(40, 10),
+		Timing(0, time.Second, 2*time.Second))) {
+		t.Error("stream not deterministic")
+	}
+	intervals, err := pgs.Intervals(pgs.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) != 6 {
+		t.Fatalf("got %d intervals, want 6", len(intervals))
+	}
+	for i, iv := range intervals {
+		if want := time.Duration(i) * 2 * time.Second; iv.Start != want || iv.Duration() != time.Second {
+			t.Errorf("interval %d is %s to %s, want %s for 1s", i, iv.Start, iv.End, want)
+		}
+		if _, err := iv.DisplaySet.Render(); err != nil {
+			t.Errorf("interval %d: %v", i, err)
+		}
+	}
+	epochs := 0
+	er := pgs.NewEpochReader(pgs.NewReader(bytes.NewReader(b)))
+	for {
+		_, err := er.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs++
+	}
+	if epochs != 2 {
+		t.Errorf("got %d epochs, want 2", epochs)
+	}
+}
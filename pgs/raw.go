@@ -5,17 +5,18 @@ import (
 	"time"
 )
 
-type header struct {
+// Header is the raw header preceding each segment, as stored on disk.
+type Header struct {
 	MagicNumber      uint16    // "PG" 0x5047
-	PresentationTime timestamp // When sub picture is shown on screen
-	DecodingTime     timestamp // When sub picture decoding starts
+	PresentationTime Timestamp // When sub picture is shown on screen
+	DecodingTime     Timestamp // When sub picture decoding starts
 	SegmentType      SegmentType
 	SegmentSize      uint16
 }
 
 type pcs struct {
 	Width, Height     uint16 // Video dimensions in pixels
-	FrameRate         uint8  // Always 0x10; can be ignored
+	FrameRate         FrameRate
 	CompositionNumber uint16
 	CompositionState  CompositionState // Type of this composition
 	PaletteUpdateFlag paletteUpdateFlag
@@ -47,9 +48,26 @@ type ods struct {
 	Width, Height    uint16       // Dimensions of the image
 }
 
+// odsFragment is the header of an object definition segment continuing
+// the data of the previous one, which declares the length and dimensions.
+type odsFragment struct {
+	ObjectID      uint16
+	ObjectVersion uint8
+	SequenceFlag  sequenceFlag
+}
+
+// odsSize is the rest of the header of the first object definition
+// segment of an object, after the fields of odsFragment.
+type odsSize struct {
+	ObjectDataLength uint24
+	Width, Height    uint16
+}
+
+// Timestamp is a time in units of a 90 kHz clock.
+type Timestamp uint32
+
 type (
-	timestamp uint32
-	uint24    [3]uint8
+	uint24 [3]uint8
 
 	paletteUpdateFlag uint8
 	objectCroppedFlag uint8
@@ -60,21 +78,42 @@ const (
 	pufFalse paletteUpdateFlag = 0x00
 	pufTrue  paletteUpdateFlag = 0x80
 
-	croppedForce objectCroppedFlag = 0x40 // Force display of the cropped image object
-	croppedOff   objectCroppedFlag = 0x00 // Off
+	croppedOn  objectCroppedFlag = 0x80 // Cropping rectangle follows
+	forcedOn   objectCroppedFlag = 0x40 // Force display of the object
+	croppedOff objectCroppedFlag = 0x00 // Off
 
 	lastInSequence  sequenceFlag = 0x40
 	firstInSequence sequenceFlag = 0x80
 )
 
-// Duration converts a timestamp into a Duration. Timestamps have an
+// Duration converts a Timestamp into a Duration. Timestamps have an
 // accuracy of 90 kHz, so divide by 90 to get milliseconds.
-func (ts timestamp) Duration() time.Duration {
+func (ts Timestamp) Duration() time.Duration {
 	return time.Duration(ts) * time.Millisecond / 90
 }
 
-func fromDuration(d time.Duration) timestamp {
-	return timestamp(d * 90 / time.Millisecond)
+// WrapPeriod is the time after which the 32-bit 90 kHz clock of
+// timestamps wraps around, about 13h15m.
+const WrapPeriod = time.Duration(1<<32) * time.Millisecond / 90
+
+// Sub returns the time from u to ts, assuming that they are less than
+// half of WrapPeriod apart, so that the difference is correct across a
+// wraparound of the clock.
+func (ts Timestamp) Sub(u Timestamp) time.Duration {
+	return ticksDuration(int64(int32(ts - u)))
+}
+
+// ticksDuration converts a count of 90 kHz clock ticks, which may exceed
+// the range of a Timestamp, into a Duration.
+func ticksDuration(ticks int64) time.Duration {
+	return time.Duration(ticks) * time.Millisecond / 90
+}
+
+// fromDuration converts a Duration into a Timestamp, rounded to the
+// nearest tick, so that the truncated durations of timestamps convert
+// back exactly, and wrapping around durations that exceed WrapPeriod.
+func fromDuration(d time.Duration) Timestamp {
+	return Timestamp((d*90 + time.Millisecond/2) / time.Millisecond)
 }
 
 func (ui uint24) Int() int {
@@ -1,18 +1,313 @@
 package pgs
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"time"
 )
 
 type Reader struct {
-	r io.Reader
+	r           *source
+	skipData    bool
+	keepHeaders bool
+	keepPayload bool
+	offset      time.Duration // Added to timestamps of display sets
+	seq         []sequencePart
+	noUnwrap    bool
+	wraps       int64     // Number of wraparounds of presentation timestamps
+	lastPTS     Timestamp // Presentation timestamp of the previous display set
+	trailing    bool
+	ended       bool  // Whether a display set has been read
+	trailingLen int64 // Length of data ignored after the last display set
+	verify      func(raw []byte) error
+	leading     []LeadingHeader
+	leadChecked bool  // Whether the current source was checked for a leading header
+	leadingLen  int64 // Length of leading headers skipped
+	inSequence  bool  // Whether the last object read began a sequence not yet ended
+	spill       *os.File
+	spillOff    int64 // End of data written to spill
+	truncation  bool
+	truncLen    int64 // Length of incomplete display sets dropped
+	unknown     bool
+	resync      bool
+	skipBad     bool
+	warn        func(offset int64, err error)
+	defs        *epochDefs // Definitions of the current epoch
+}
+
+// LeadingHeader describes a container header written by some tools
+// before the first segment of a file.
+type LeadingHeader struct {
+	Magic []byte // Signature at the start of the file
+	Size  int    // Length of the header, including the signature
+}
+
+// source counts the bytes read from an underlying reader and, when
+// recording, keeps the bytes read since the last reset.
+type source struct {
+	r         io.Reader
+	n         int64
+	recording bool
+	rec       []byte
+	ring      *ring  // Recent bytes, when kept for HexContext
+	budget    *int64 // Bytes left to read, when limited by SetMaxBytes
+}
+
+// ErrMaxBytes is returned when reading would exceed the limit set by
+// SetMaxBytes.
+var ErrMaxBytes = errors.New("byte limit of reader exceeded")
+
+func (s *source) Read(b []byte) (int, error) {
+	if s.budget != nil {
+		if *s.budget <= 0 {
+			// Distinguish the end of a stream of exactly the limit
+			var probe [1]byte
+			if n, err := s.r.Read(probe[:]); n == 0 {
+				return 0, err
+			}
+			return 0, ErrMaxBytes
+		}
+		if int64(len(b)) > *s.budget {
+			b = b[:*s.budget]
+		}
+	}
+	n, err := s.r.Read(b)
+	s.n += int64(n)
+	if s.budget != nil {
+		*s.budget -= int64(n)
+	}
+	if s.recording {
+		s.rec = append(s.rec, b[:n]...)
+	}
+	if s.ring != nil {
+		s.ring.write(b[:n])
+	}
+	return n, err
+}
+
+// ring is a fixed-size buffer of the most recent bytes written to it.
+type ring struct {
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func (r *ring) write(b []byte) {
+	if len(b) > len(r.buf) {
+		b = b[len(b)-len(r.buf):]
+	}
+	n := copy(r.buf[r.pos:], b)
+	copy(r.buf, b[n:])
+	if r.pos+len(b) >= len(r.buf) {
+		r.full = true
+	}
+	r.pos = (r.pos + len(b)) % len(r.buf)
+}
+
+// last returns the most recent n bytes, or fewer if fewer were written.
+func (r *ring) last(n int) []byte {
+	size := r.pos
+	if r.full {
+		size = len(r.buf)
+	}
+	if n > size {
+		n = size
+	}
+	b := make([]byte, 0, n)
+	start := r.pos - n
+	if start < 0 {
+		b = append(b, r.buf[len(r.buf)+start:]...)
+		start = 0
+	}
+	return append(b, r.buf[start:r.pos]...)
+}
+
+func (s *source) reset() {
+	s.rec = s.rec[:0]
 }
 
 func NewReader(r io.Reader) *Reader {
-	return &Reader{r}
+	return &Reader{r: &source{r: r}}
+}
+
+// SkipObjectData controls whether object data is read. When skipped,
+// Object.Data is left nil and only the object metadata is populated,
+// which makes indexing large streams cheap.
+func (r *Reader) SkipObjectData(skip bool) {
+	r.skipData = skip
+}
+
+// SpillObjectData sets the reader to write object data to f, such as a
+// temporary file, rather than holding it in memory, for processing large
+// objects with little memory. Object.Data is then left nil and the data
+// is read with Object.DataReader or loaded with Object.LoadData. The
+// caller owns f and must keep it open while the objects are in use.
+// Passing nil restores the default of reading data into memory.
+func (r *Reader) SpillObjectData(f *os.File) {
+	r.spill = f
+}
+
+// KeepHeaders controls whether the raw header of each segment is kept
+// in DisplaySet.Headers, for inspecting the exact on-disk values.
+func (r *Reader) KeepHeaders(keep bool) {
+	r.keepHeaders = keep
+}
+
+// KeepPayloads controls whether the raw payload of each segment is kept
+// in DisplaySet.Payloads, so that a Writer can pass it through verbatim.
+// The headers of the segments are kept with them in DisplaySet.Headers.
+func (r *Reader) KeepPayloads(keep bool) {
+	r.keepPayload = keep
+}
+
+// AllowTrailingData controls whether data after the last display set
+// that does not begin a valid segment, such as muxer padding, is treated
+// as the end of the stream rather than an error. The length of ignored
+// data is reported by TrailingLen.
+func (r *Reader) AllowTrailingData(allow bool) {
+	r.trailing = allow
+}
+
+// SkipLeadingHeaders sets the container headers recognized at the start
+// of the stream. When the stream begins with the signature of one, the
+// header is skipped rather than failing the magic number check of the
+// first segment. Streams without a recognized signature are read as is.
+// The length of skipped headers is reported by LeadingLen.
+func (r *Reader) SkipLeadingHeaders(headers ...LeadingHeader) {
+	r.leading = headers
+}
+
+// LeadingLen returns the number of bytes of leading headers skipped.
+func (r *Reader) LeadingLen() int64 {
+	return r.leadingLen
+}
+
+// AllowUnknownSegments controls whether segments of unrecognized types
+// that are not registered with RegisterSegmentType are kept undecoded in
+// DisplaySet.Vendor rather than being an error, which is the default.
+func (r *Reader) AllowUnknownSegments(allow bool) {
+	r.unknown = allow
+}
+
+// AllowTruncation controls whether a display set cut short by the end of
+// the stream, as in an incomplete download, is dropped and treated as the
+// end of the stream rather than an error. The length of dropped data is
+// reported by TruncatedLen.
+func (r *Reader) AllowTruncation(allow bool) {
+	r.truncation = allow
+}
+
+// Resync controls whether the reader recovers from damaged data, as in
+// some ripped files, rather than failing. Bytes that do not begin a
+// presentation composition segment, such as garbage before the first
+// display set or between display sets, are skipped up to the next "PG"
+// magic number beginning a valid presentation composition header,
+// which also skips the segments of partial display sets. A display
+// set that fails to parse is dropped and reading resumes at the next
+// one, an incomplete display set at the end of the stream is dropped,
+// and the stream ends at data that cannot be resynchronized. Each
+// recovery is reported to the function set by SetWarn.
+func (r *Reader) Resync(resync bool) {
+	r.resync = resync
+}
+
+// SkipUndecodableSegments controls whether window, palette, object, and
+// vendor segments that fail to decode, including segments of
+// unrecognized types, are skipped by their declared sizes, with a
+// warning to the function set by SetWarn, rather than failing the
+// display set. Their payloads are still kept by KeepPayloads.
+func (r *Reader) SkipUndecodableSegments(skip bool) {
+	r.skipBad = skip
+}
+
+// SetWarn sets a function to be called with the byte offset in the
+// stream and a description of each problem recovered from by Resync and
+// SkipUndecodableSegments.
+func (r *Reader) SetWarn(fn func(offset int64, err error)) {
+	r.warn = fn
+}
+
+// warnf reports a recovered problem at offset, when warnings are set.
+func (r *Reader) warnf(offset int64, format string, args ...any) {
+	if r.warn != nil {
+		r.warn(offset, fmt.Errorf(format, args...))
+	}
+}
+
+// TruncatedLen returns the number of bytes of incomplete display sets
+// dropped when truncation is allowed.
+func (r *Reader) TruncatedLen() int64 {
+	return r.truncLen
+}
+
+// SetMaxBytes limits the total bytes read from the stream to n, after
+// which reading fails with ErrMaxBytes, regardless of the lengths the
+// stream declares. It bounds the resources used for untrusted input and
+// should be set before reading. Leading headers skipped by
+// SkipLeadingHeaders are not counted. A limit of 0 or less removes it.
+func (r *Reader) SetMaxBytes(n int64) {
+	if n <= 0 {
+		r.r.budget = nil
+		return
+	}
+	r.r.budget = &n
+}
+
+// KeepContext sets the reader to keep the most recent size bytes read,
+// so that HexContext can show the bytes around a parse failure. A size
+// of 0 disables it, which is the default.
+func (r *Reader) KeepContext(size int) {
+	if size <= 0 {
+		r.r.ring = nil
+		return
+	}
+	r.r.ring = &ring{buf: make([]byte, size)}
+}
+
+// HexContext returns a hex dump with ASCII of the last n bytes read, or
+// of fewer when fewer are kept. It is empty unless enabled by
+// KeepContext.
+func (r *Reader) HexContext(n int) string {
+	if r.r.ring == nil {
+		return ""
+	}
+	return hex.Dump(r.r.ring.last(n))
+}
+
+// InObjectSequence reports whether an object definition that is first in
+// its sequence has been read, but not the last in the sequence, so the
+// object is incomplete.
+func (r *Reader) InObjectSequence() bool {
+	return r.inSequence
+}
+
+// TrailingLen returns the number of bytes ignored after the last display
+// set when trailing data is allowed.
+func (r *Reader) TrailingLen() int64 {
+	return r.trailingLen
+}
+
+// UnwrapTimestamps controls whether wraparounds of the 32-bit 90 kHz
+// clock, which occur about every 13 hours, are detected. It is enabled
+// by default and assumes that presentation timestamps are monotonic in
+// the source, so a backwards jump of more than half of WrapPeriod is
+// counted as a wraparound and WrapPeriod is added to the times of the
+// following display sets.
+func (r *Reader) UnwrapTimestamps(unwrap bool) {
+	r.noUnwrap = !unwrap
+}
+
+// SetVerify sets a function to be called with the raw bytes of each
+// segment, header included, after it has been read, such as to check
+// integrity data from a container. An error returned by fn fails the
+// read. The slice is only valid during the call.
+func (r *Reader) SetVerify(fn func(raw []byte) error) {
+	r.verify = fn
 }
 
 func (r *Reader) ReadAll() ([]DisplaySet, error) {
@@ -29,13 +324,153 @@ func (r *Reader) ReadAll() ([]DisplaySet, error) {
 	}
 }
 
+// ReadN reads at most n display sets. It stops early without error when
+// the end of the stream is reached first.
+func (r *Reader) ReadN(n int) ([]DisplaySet, error) {
+	var stream []DisplaySet
+	for len(stream) < n {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		stream = append(stream, *ds)
+	}
+	return stream, nil
+}
+
+// ReadUntilComposition reads forward to the display set with the given
+// composition number and returns it.
+func (r *Reader) ReadUntilComposition(n uint16) (*DisplaySet, error) {
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("composition number %d not found before end of stream", n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.CompositionNumber == n {
+			return ds, nil
+		}
+	}
+}
+
+// ReadComplete reads the display sets of r, dropping a trailing display
+// set cut short by the end of the stream, to salvage the complete display
+// sets of a truncated file.
+func ReadComplete(r io.Reader) ([]*DisplaySet, error) {
+	sr := NewReader(r)
+	sr.AllowTruncation(true)
+	var stream []*DisplaySet
+	for {
+		ds, err := sr.Read()
+		if err == io.EOF {
+			return stream, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		stream = append(stream, ds)
+	}
+}
+
+// StreamObjects calls fn with each object defined by the display sets,
+// in stream order. Display sets are not retained, so memory use does not
+// grow with the length of the stream. An error returned by fn stops the
+// stream and is returned.
+func (r *Reader) StreamObjects(fn func(*Object) error) error {
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, obj := range ds.DefinedObjects() {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (r *Reader) Read() (*DisplaySet, error) {
+	for {
+		start := r.r.n
+		ds, err := r.read()
+		if err != nil && err != io.EOF && r.truncation &&
+			(errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			r.truncLen += r.r.n - start
+			err = io.EOF
+		}
+		if err != nil && err != io.EOF && r.resync && !errors.Is(err, ErrMaxBytes) {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				r.warnf(start, "dropped incomplete display set: %w", err)
+				err = io.EOF
+			} else {
+				r.warnf(start, "dropped display set: %w", err)
+				continue
+			}
+		}
+		if err == io.EOF && len(r.seq) != 0 {
+			r.r, r.offset = &source{r: r.seq[0].r, ring: r.r.ring, budget: r.r.budget}, r.seq[0].offset
+			r.seq = r.seq[1:]
+			r.wraps, r.lastPTS, r.ended, r.leadChecked = 0, 0, false, false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ds.PresentationTime += r.offset
+		ds.DecodingTime += r.offset
+		r.define(ds)
+		return ds, nil
+	}
+}
+
+// define links the display set to the definitions earlier in its epoch
+// and adds its own.
+func (r *Reader) define(ds *DisplaySet) {
+	if r.defs == nil || ds.CompositionState == EpochStart {
+		r.defs = &epochDefs{}
+	}
+	ds.prior = r.defs
+	r.defs = r.defs.with(ds)
+}
+
+func (r *Reader) read() (*DisplaySet, error) {
 	var ds DisplaySet
 
-	h0, err := r.readHeader()
+	if !r.leadChecked {
+		r.leadChecked = true
+		if err := r.skipLeadingHeader(); err != nil {
+			return nil, fmt.Errorf("leading header: %w", err)
+		}
+	}
+	start := r.r.n
+	r.r.recording = r.verify != nil || r.keepPayload
+	r.r.reset()
+	var h0 *Header
+	var err error
+	if r.resync {
+		h0, err = r.resyncHeader()
+	} else {
+		h0, err = r.readHeader()
+	}
 	if err == io.EOF {
 		return nil, err
 	}
+	if err != nil && r.trailing && r.ended {
+		if _, err := io.Copy(io.Discard, r.r); err != nil {
+			return nil, err
+		}
+		r.trailingLen += r.r.n - start
+		return nil, io.EOF
+	}
 	if err != nil {
 		return nil, fmt.Errorf("segment header: %w", err)
 	}
@@ -46,11 +481,17 @@ func (r *Reader) Read() (*DisplaySet, error) {
 	if err != nil {
 		return nil, fmt.Errorf("presentation composition segment: %w", err)
 	}
-	ds.PresentationTime = h0.PresentationTime.Duration()
-	ds.DecodingTime = h0.DecodingTime.Duration()
+	if err := r.endSegment(&ds, h0); err != nil {
+		return nil, err
+	}
+	ds.PresentationTime, ds.DecodingTime = r.times(h0)
 	ds.PresentationComposition = *c
+	if r.keepHeaders || r.keepPayload {
+		ds.Headers = append(ds.Headers, *h0)
+	}
 
 	for {
+		r.r.reset()
 		h, err := r.readHeader()
 		if err != nil {
 			return nil, fmt.Errorf("segment header: %w", err)
@@ -63,60 +504,251 @@ func (r *Reader) Read() (*DisplaySet, error) {
 			return nil, fmt.Errorf("decoding time not consistent: PCS is %s, %s is %s",
 				ds.DecodingTime, h.SegmentType, h.DecodingTime.Duration())
 		}
+		if r.keepHeaders || r.keepPayload {
+			ds.Headers = append(ds.Headers, *h)
+		}
 
-		switch h.SegmentType {
-		case PCSType:
-			return nil, errors.New("presentation composition not ended")
-		case WDSType:
-			if len(ds.Windows) != 0 {
-				return nil, errors.New("multiple window definitions")
-			}
-			w, err := r.readWindows(h.SegmentSize)
-			if err != nil {
-				return nil, fmt.Errorf("window definition segment: %w", err)
-			}
-			ds.Windows = w
-		case PDSType:
-			if ds.Palette != nil {
-				return nil, errors.New("multiple palette definitions")
-			}
-			p, err := r.readPalette(h.SegmentSize)
-			if err != nil {
-				return nil, fmt.Errorf("palette definition segment: %w", err)
+		if err := r.readSegment(&ds, h); err != nil {
+			return nil, err
+		}
+		if err := r.endSegment(&ds, h); err != nil {
+			return nil, err
+		}
+		if h.SegmentType == ENDType {
+			r.ended = true
+			return &ds, nil
+		}
+	}
+}
+
+// readSegment reads a segment of a display set after its presentation
+// composition into ds.
+func (r *Reader) readSegment(ds *DisplaySet, h *Header) error {
+	start := r.r.n
+	err := r.decodeSegment(ds, h)
+	if err == nil || !r.skipBad || h.SegmentType == PCSType || h.SegmentType == ENDType ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrMaxBytes) {
+		return err
+	}
+	rest := start + int64(h.SegmentSize) - r.r.n
+	if rest < 0 {
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, r.r, rest); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	r.warnf(start-headerSize, "skipped segment: %w", err)
+	return nil
+}
+
+// decodeSegment decodes a segment of a display set after its
+// presentation composition into ds.
+func (r *Reader) decodeSegment(ds *DisplaySet, h *Header) error {
+	switch h.SegmentType {
+	case PCSType:
+		return errors.New("presentation composition not ended")
+	case WDSType:
+		if len(ds.Windows) != 0 {
+			return errors.New("multiple window definitions")
+		}
+		w, err := r.readWindows(h.SegmentSize)
+		if err != nil {
+			return fmt.Errorf("window definition segment: %w", err)
+		}
+		ds.Windows = w
+	case PDSType:
+		p, err := r.readPalette(h.SegmentSize)
+		if err != nil {
+			return fmt.Errorf("palette definition segment: %w", err)
+		}
+		for _, prev := range ds.DefinedPalettes() {
+			if prev.ID == p.ID {
+				return fmt.Errorf("palette %d defined twice", p.ID)
 			}
-			ds.Palette = p
-		case ODSType:
-			if ds.Object != nil {
-				return nil, errors.New("multiple object definitions")
+		}
+		ds.definePalette(p)
+	case ODSType:
+		if h.SegmentSize < 4 {
+			return fmt.Errorf("object definition segment: segment size %d excludes object header", h.SegmentSize)
+		}
+		var frag odsFragment
+		if err := binary.Read(r.r, binary.BigEndian, &frag); err != nil {
+			return fmt.Errorf("object definition segment: %w", err)
+		}
+		// A segment that is not first in its sequence continues the data
+		// of the incomplete object with its ID
+		prev, _ := ds.object(frag.ObjectID)
+		if prev != nil && prev.First && !prev.Last && frag.SequenceFlag&firstInSequence == 0 {
+			if err := r.readObjectFragment(prev, &frag, h.SegmentSize); err != nil {
+				return fmt.Errorf("object definition segment: %w", err)
 			}
-			o, err := r.readObject(h.SegmentSize)
-			if err != nil {
-				return nil, fmt.Errorf("object definition segment: %w", err)
+			r.inSequence = !prev.Last
+			break
+		}
+		if prev != nil {
+			return fmt.Errorf("object %d defined twice", frag.ObjectID)
+		}
+		o, err := r.readObject(&frag, h.SegmentSize)
+		if err != nil {
+			return fmt.Errorf("object definition segment: %w", err)
+		}
+		ds.defineObject(o)
+		if o.First || o.Last {
+			r.inSequence = o.First && !o.Last
+		}
+	case ENDType:
+	default:
+		if _, ok := segmentDecoder(h.SegmentType); !ok && !r.unknown {
+			return fmt.Errorf("unrecognized segment type: %s", h.SegmentType)
+		}
+		seg, err := r.readVendor(h)
+		if err != nil {
+			return fmt.Errorf("vendor segment %s: %w", h.SegmentType, err)
+		}
+		ds.Vendor = append(ds.Vendor, *seg)
+	}
+	return nil
+}
+
+// endSegment calls the verify function, if set, with the bytes of the
+// segment just read and keeps its payload, if enabled.
+func (r *Reader) endSegment(ds *DisplaySet, h *Header) error {
+	if r.verify != nil {
+		if err := r.verify(r.r.rec); err != nil {
+			return fmt.Errorf("verify %s segment: %w", h.SegmentType, err)
+		}
+	}
+	if r.keepPayload {
+		ds.Payloads = append(ds.Payloads, append([]byte(nil), r.r.rec[headerSize:]...))
+	}
+	return nil
+}
+
+// skipLeadingHeader skips a recognized header at the start of the
+// source. Bytes read to check the signature are otherwise put back.
+func (r *Reader) skipLeadingHeader() error {
+	n := 0
+	for _, h := range r.leading {
+		if len(h.Magic) > n {
+			n = len(h.Magic)
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	peek := make([]byte, n)
+	n, err := io.ReadFull(r.r.r, peek)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	peek = peek[:n]
+	for _, h := range r.leading {
+		if len(h.Magic) == 0 || !bytes.HasPrefix(peek, h.Magic) {
+			continue
+		}
+		if h.Size < len(h.Magic) {
+			return fmt.Errorf("size %d shorter than signature % x", h.Size, h.Magic)
+		}
+		if h.Size <= len(peek) {
+			peek = peek[h.Size:]
+		} else {
+			if _, err := io.CopyN(io.Discard, r.r.r, int64(h.Size-len(peek))); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
 			}
-			ds.Object = o
-		case ENDType:
-			return &ds, nil
+			peek = nil
 		}
+		r.r.n += int64(h.Size)
+		r.leadingLen += int64(h.Size)
+		break
+	}
+	if len(peek) != 0 {
+		r.r.r = io.MultiReader(bytes.NewReader(peek), r.r.r)
+	}
+	return nil
+}
+
+// times converts the timestamps of the header to durations, counting
+// wraparounds of the clock unless disabled.
+func (r *Reader) times(h *Header) (pts, dts time.Duration) {
+	if r.noUnwrap {
+		return h.PresentationTime.Duration(), h.DecodingTime.Duration()
 	}
+	if h.PresentationTime < r.lastPTS && r.lastPTS-h.PresentationTime > 1<<31 {
+		r.wraps++
+	}
+	r.lastPTS = h.PresentationTime
+	ticks := r.wraps<<32 + int64(h.PresentationTime)
+	return ticksDuration(ticks), ticksDuration(ticks - int64(h.PresentationTime-h.DecodingTime))
 }
 
-func (r *Reader) readHeader() (*header, error) {
-	var h header
+func (r *Reader) readHeader() (*Header, error) {
+	var h Header
 	if err := binary.Read(r.r, binary.BigEndian, &h); err != nil {
 		return nil, err
 	}
-	if err := h.validate(); err != nil {
+	// Unknown segments to skip are rejected by decodeSegment
+	if err := h.check(r.unknown || r.skipBad); err != nil {
 		return nil, err
 	}
 	return &h, nil
 }
 
+// resyncHeader reads the header of the next presentation composition
+// segment, skipping bytes up to the next "PG" magic number beginning a
+// valid one. Other segments are scanned rather than skipped by their
+// declared sizes, which cannot be trusted in partial display sets. The
+// stream ends at data that cannot be resynchronized.
+func (r *Reader) resyncHeader() (*Header, error) {
+	start := r.r.n
+	var buf [headerSize]byte
+	n := 0
+	skipped := int64(0)
+	for {
+		k, err := io.ReadFull(r.r, buf[n:])
+		n += k
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if skipped+int64(n) != 0 {
+				r.warnf(start, "skipped %d bytes at end of stream", skipped+int64(n))
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		var h Header
+		if err := binary.Read(bytes.NewReader(buf[:]), binary.BigEndian, &h); err != nil {
+			return nil, err
+		}
+		if h.SegmentType == PCSType && h.check(false) == nil {
+			if skipped != 0 {
+				r.warnf(start, "skipped %d bytes to resynchronize", skipped)
+			}
+			if r.r.recording {
+				r.r.rec = append(r.r.rec[:0], buf[:]...)
+			}
+			return &h, nil
+		}
+		i := bytes.IndexByte(buf[1:], 'P') + 1
+		if i == 0 {
+			i = headerSize
+		}
+		n = copy(buf[:], buf[i:])
+		skipped += int64(i)
+	}
+}
+
 func (r *Reader) readPresentationComposition(segmentSize uint16) (*PresentationComposition, error) {
 	var pcs pcs
 	if err := binary.Read(r.r, binary.BigEndian, &pcs); err != nil {
 		return nil, err
 	}
-	if err := pcs.validate(); err != nil {
+	if err := pcs.validate(segmentSize); err != nil {
 		return nil, err
 	}
 	size := 11
@@ -134,8 +766,12 @@ func (r *Reader) readPresentationComposition(segmentSize uint16) (*PresentationC
 			WindowID: obj.WindowID,
 			X:        obj.X,
 			Y:        obj.Y,
+			Forced:   obj.ObjectCropped&forcedOn != 0,
 		}
-		if obj.ObjectCropped == croppedForce {
+		if obj.ObjectCropped&croppedOn != 0 {
+			if size+16 > int(segmentSize) {
+				return nil, fmt.Errorf("composition object %d/%d: crop exceeds segment size %d", i+1, pcs.ObjectCount, segmentSize)
+			}
 			var crop CompositionObjectCrop
 			if err := binary.Read(r.r, binary.BigEndian, &crop); err != nil {
 				return nil, err
@@ -170,10 +806,18 @@ func (r *Reader) readWindows(segmentSize uint16) ([]Window, error) {
 		return nil, err
 	}
 	windows := make([]Window, wds.WindowCount)
+	var ids [256]bool
 	for i := range windows {
 		if err := binary.Read(r.r, binary.BigEndian, &windows[i]); err != nil {
 			return nil, err
 		}
+		// Degenerate windows are kept, for Validate to report, as the
+		// composition can still be decoded, but objects are placed by
+		// window ID, so a reused ID is ambiguous
+		if ids[windows[i].ID] {
+			return nil, fmt.Errorf("window id reused: %d", windows[i].ID)
+		}
+		ids[windows[i].ID] = true
 	}
 	return windows, nil
 }
@@ -185,10 +829,8 @@ func (r *Reader) readPalette(segmentSize uint16) (*Palette, error) {
 	}
 	n := (segmentSize - 2) / 5
 	entries := make([]PaletteEntry, n)
-	for i := range entries {
-		if err := binary.Read(r.r, binary.BigEndian, &entries[i]); err != nil {
-			return nil, err
-		}
+	if err := binary.Read(r.r, binary.BigEndian, entries); err != nil {
+		return nil, err
 	}
 	p := &Palette{
 		ID:      pds.PaletteID,
@@ -201,24 +843,20 @@ func (r *Reader) readPalette(segmentSize uint16) (*Palette, error) {
 	return p, nil
 }
 
-func (r *Reader) readObject(segmentSize uint16) (*Object, error) {
-	var ods ods
-	if err := binary.Read(r.r, binary.BigEndian, &ods); err != nil {
+// readObject reads an object definition segment after the header frag
+// common to all fragments.
+func (r *Reader) readObject(frag *odsFragment, segmentSize uint16) (*Object, error) {
+	var size odsSize
+	if err := binary.Read(r.r, binary.BigEndian, &size); err != nil {
 		return nil, err
 	}
+	ods := ods{frag.ObjectID, frag.ObjectVersion, frag.SequenceFlag, size.ObjectDataLength, size.Width, size.Height}
 	if err := ods.validate(segmentSize); err != nil {
 		return nil, err
 	}
-	dataLen := ods.ObjectDataLength.Int() - 4
-	data := make([]byte, dataLen)
-	n := 0
-	for n < dataLen {
-		n0, err := r.r.Read(data[n:])
-		if err != nil {
-			return nil, err
-		}
-		n += n0
-	}
+	// The data of a fragmented object continues in the following object
+	// definition segments
+	dataLen := min(ods.ObjectDataLength.Int(), int(segmentSize)-7) - 4
 	obj := &Object{
 		ID:      ods.ObjectID,
 		Version: ods.ObjectVersion,
@@ -227,8 +865,69 @@ func (r *Reader) readObject(segmentSize uint16) (*Object, error) {
 		Image: Image{
 			Width:  ods.Width,
 			Height: ods.Height,
-			Data:   data,
 		},
 	}
+	if err := r.readObjectData(obj, dataLen); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
+
+// readObjectFragment reads the data of an object definition segment,
+// after its header frag, continuing the data of obj, and appends it to
+// obj.
+func (r *Reader) readObjectFragment(obj *Object, frag *odsFragment, segmentSize uint16) error {
+	if frag.ObjectVersion != obj.Version {
+		return fmt.Errorf("fragment of object %d version %d continues version %d",
+			frag.ObjectID, frag.ObjectVersion, obj.Version)
+	}
+	if frag.SequenceFlag&^lastInSequence != 0 {
+		return fmt.Errorf("fragment sequence flag not last: 0x%x", frag.SequenceFlag)
+	}
+	obj.Last = frag.SequenceFlag&lastInSequence != 0
+	return r.readObjectData(obj, int(segmentSize)-4)
+}
+
+// readObjectData reads n bytes of object data and appends them to obj,
+// which is either kept in memory, spilled, or skipped.
+func (r *Reader) readObjectData(obj *Object, n int) error {
+	obj.DataLen += n
+	obj.fragments = append(obj.fragments, n)
+	if r.skipData {
+		if _, err := io.CopyN(io.Discard, r.r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		return nil
+	}
+	if r.spill != nil {
+		w := io.NewOffsetWriter(r.spill, r.spillOff)
+		if _, err := io.CopyN(w, r.r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		// Fragments are spilled contiguously, so the section grows
+		start := r.spillOff
+		if obj.spill != nil {
+			_, start, _ = obj.spill.Outer()
+		}
+		r.spillOff += int64(n)
+		obj.spill = io.NewSectionReader(r.spill, start, r.spillOff-start)
+		return nil
+	}
+	// Grow the buffer as data arrives, rather than trusting the declared
+	// length, to bound allocation on truncated input
+	buf := bytes.NewBuffer(obj.Data)
+	if _, err := io.CopyN(buf, r.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	obj.Data = buf.Bytes()
+	return nil
+}
@@ -0,0 +1,342 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadObjectFragments(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{color.Transparent, color.White})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 3 % 2)
+	}
+	ds, err := NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).Write(ds); err != nil {
+		t.Fatal(err)
+	}
+	sup := splitObject(t, b.Bytes(), 100)
+
+	f, err := os.CreateTemp(t.TempDir(), "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, mode := range []string{"memory", "spill", "skip"} {
+		r := NewReader(bytes.NewReader(sup))
+		switch mode {
+		case "spill":
+			r.SpillObjectData(f)
+		case "skip":
+			r.SkipObjectData(true)
+		}
+		got, err := r.Read()
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		obj := got.Object
+		if !obj.First || !obj.Last || obj.DataLen != len(ds.Object.Data) || r.InObjectSequence() {
+			t.Errorf("%s: got object %v, first %t, last %t, data length %d",
+				mode, obj, obj.First, obj.Last, obj.DataLen)
+		}
+		if mode == "skip" {
+			continue
+		}
+		data, err := io.ReadAll(obj.DataReader())
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if !bytes.Equal(data, ds.Object.Data) {
+			t.Errorf("%s: object data not joined", mode)
+		}
+	}
+	// Truncated within the data of the last fragment, before the END
+	// segment
+	for _, mode := range []string{"memory", "spill", "skip"} {
+		r := NewReader(bytes.NewReader(sup[:len(sup)-headerSize-5]))
+		switch mode {
+		case "spill":
+			r.SpillObjectData(f)
+		case "skip":
+			r.SkipObjectData(true)
+		}
+		if _, err := r.Read(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: truncated object read with error %v", mode, err)
+		}
+	}
+}
+
+// splitObject splits the data of the first object definition segment in
+// the stream after n bytes into a continuation fragment.
+func splitObject(t *testing.T, sup []byte, n int) []byte {
+	t.Helper()
+	for off := 0; off+headerSize <= len(sup); {
+		size := int(binary.BigEndian.Uint16(sup[off+11:]))
+		if SegmentType(sup[off+10]) != ODSType {
+			off += headerSize + size
+			continue
+		}
+		payload := sup[off+headerSize : off+headerSize+size]
+		var out []byte
+		out = append(out, sup[:off]...)
+		h := append([]byte(nil), sup[off:off+headerSize]...)
+		first := append([]byte(nil), payload[:11+n]...)
+		first[3] = byte(firstInSequence)
+		binary.BigEndian.PutUint16(h[11:], uint16(len(first)))
+		out = append(append(out, h...), first...)
+		rest := append([]byte{payload[0], payload[1], payload[2], byte(lastInSequence)}, payload[11+n:]...)
+		binary.BigEndian.PutUint16(h[11:], uint16(len(rest)))
+		out = append(append(out, h...), rest...)
+		return append(out, sup[off+headerSize+size:]...)
+	}
+	t.Fatal("no object definition segment")
+	return nil
+}
+
+func TestReadResync(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Transparent, color.White})
+	img.Pix[1] = 1
+	show, err := NewDisplaySet(img, 3, 4, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clear := NewClearDisplaySet(2*time.Second, 0)
+	segments := func(ds *DisplaySet) []byte {
+		var b bytes.Buffer
+		if err := NewWriter(&b).Write(ds); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	s, c := segments(show), segments(clear)
+
+	// An unknown segment before END
+	bad := append([]byte(nil), s[:len(s)-headerSize]...)
+	bad = append(bad, s[:10]...)
+	bad = append(bad, 0x42, 0, 1, 0xff)
+	bad = append(bad, s[len(s)-headerSize:]...)
+	var sup []byte
+	sup = append(sup, "garbage PG"...)
+	sup = append(sup, bad...)
+	sup = append(sup, s[headerSize:50]...) // Partial display set
+	sup = append(sup, c...)
+	sup = append(sup, s[:len(s)-headerSize]...) // Truncated
+
+	var warnings []string
+	r := NewReader(bytes.NewReader(sup))
+	r.Resync(true)
+	r.SkipUndecodableSegments(true)
+	r.SetWarn(func(offset int64, err error) {
+		warnings = append(warnings, err.Error())
+	})
+	stream, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream) != 2 || stream[0].Object == nil || stream[1].PresentationTime != 2*time.Second {
+		t.Errorf("got %d display sets, want show and clear: %q", len(stream), warnings)
+	}
+	if len(warnings) != 4 {
+		t.Errorf("got %d warnings, want 4: %q", len(warnings), warnings)
+	}
+
+	if _, err := NewReader(bytes.NewReader(sup)).ReadAll(); err == nil {
+		t.Error("strict reader read damaged stream")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Transparent, color.White})
+	show, err := NewDisplaySet(img, 3, 4, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show.Width, show.Height = 20, 10
+	clear := NewClearDisplaySet(2*time.Second, 0)
+	clear.Width, clear.Height = 20, 10
+	clear.CompositionNumber = 1
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	if err := w.Write(show); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(clear); err != nil {
+		t.Fatal(err)
+	}
+	diags, err := Validate(bytes.NewReader(b.Bytes()))
+	if err != nil || len(diags) != 0 {
+		t.Errorf("valid stream: got %v, %v", diags, err)
+	}
+
+	show.Objects[0].X = 18 // Outside the frame and window, and not cleared
+	b.Reset()
+	if err := w.Write(show); err != nil {
+		t.Fatal(err)
+	}
+	diags, err = Validate(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs, warns int
+	for _, d := range diags {
+		if d.Severity == Error {
+			errs++
+		} else {
+			warns++
+		}
+	}
+	if errs != 1 || warns != 2 {
+		t.Errorf("got %d errors and %d warnings, want 1 and 2: %v", errs, warns, diags)
+	}
+
+	// A degenerate window is read and reported rather than failing
+	show.Objects[0].X = 3
+	show.Windows[0].Height = 0
+	b.Reset()
+	if err := w.Write(show); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(clear); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(bytes.NewReader(b.Bytes())).ReadAll(); err != nil {
+		t.Errorf("degenerate window: %v", err)
+	}
+	diags, err = Validate(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) == 0 || diags[0].Severity != Error || !strings.Contains(diags[0].Message, "degenerate") {
+		t.Errorf("degenerate window: got %v", diags)
+	}
+}
+
+func TestReadMultipleObjects(t *testing.T) {
+	top := image.NewPaletted(image.Rect(0, 0, 6, 2), color.Palette{color.Transparent, color.White})
+	bottom := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Transparent, color.White})
+	top.Pix[1], bottom.Pix[2] = 1, 1
+	ds, err := NewDisplaySet(top, 2, 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewDisplaySet(bottom, 3, 6, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := *other.Object
+	obj.ID = 1
+	ds.ExtraObjects = []Object{obj}
+	ds.Windows = append(ds.Windows, Window{ID: 1, X: 3, Y: 6, Width: 4, Height: 2})
+	ds.Objects = append(ds.Objects, CompositionObject{ObjectID: 1, WindowID: 1, X: 3, Y: 6})
+	ds.Width, ds.Height = 20, 10
+	// A later composition showing both objects defined before it
+	show := NewClearDisplaySet(2*time.Second, 0)
+	show.Width, show.Height = 20, 10
+	show.CompositionNumber = 1
+	show.Objects = ds.Objects
+
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll([]DisplaySet{*ds, *show}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &stream[0]
+	if objs := got.DefinedObjects(); len(objs) != 2 || objs[0].ID != 0 || objs[1].ID != 1 ||
+		!bytes.Equal(objs[1].Data, obj.Data) {
+		t.Fatalf("got %d objects, want objects 0 and 1", len(objs))
+	}
+	if _, err := got.Render(); err != nil {
+		t.Error(err)
+	}
+	e := Epoch{stream}
+	resolved, err := e.Resolve(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objs := resolved.DefinedObjects(); len(objs) != 2 || resolved.Palette == nil {
+		t.Errorf("resolved %d objects, want 2", len(objs))
+	}
+}
+
+func TestVerticalPlacementResolved(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Transparent, color.White})
+	img.Pix[1] = 1
+	ds, err := NewDisplaySet(img, 3, 50, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Width, ds.Height = 20, 100
+	// A later composition moving the object to the top without defining
+	// it again
+	moved := NewClearDisplaySet(2*time.Second, 0)
+	moved.Width, moved.Height = 20, 100
+	moved.CompositionNumber = 1
+	moved.Objects = []CompositionObject{{X: 3, Y: 1}}
+
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll([]DisplaySet{*ds, *moved}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := stream[0].VerticalPlacement(); p != Middle {
+		t.Errorf("defining display set placed at %s, want middle", p)
+	}
+	if p := stream[1].VerticalPlacement(); p != Top {
+		t.Errorf("later display set placed at %s, want top", p)
+	}
+	if p := moved.VerticalPlacement(); p != Bottom {
+		t.Errorf("unresolved display set placed at %s, want bottom", p)
+	}
+}
+
+func TestReadTimestampWraparound(t *testing.T) {
+	// Times in ticks of the 90 kHz clock, with the second display set
+	// decoded before and presented after the clock wraps
+	ticks := []int64{1<<32 - 180000, 1<<32 + 45000, 1<<32 + 90000}
+	decoding := []int64{ticks[0], 1<<32 - 45000, ticks[2]}
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	for i := range ticks {
+		ds := NewClearDisplaySet(ticksDuration(ticks[i]), 0)
+		ds.DecodingTime = ticksDuration(decoding[i])
+		ds.Width, ds.Height = 4, 4
+		if err := w.Write(ds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, unwrap := range []bool{true, false} {
+		r := NewReader(bytes.NewReader(b.Bytes()))
+		r.UnwrapTimestamps(unwrap)
+		stream, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, ds := range stream {
+			pts, dts := ticks[i], decoding[i]
+			if !unwrap {
+				pts, dts = pts%(1<<32), dts%(1<<32)
+			}
+			if ds.PresentationTime != ticksDuration(pts) || ds.DecodingTime != ticksDuration(dts) {
+				t.Errorf("unwrap %t: display set %d at %s decoded at %s, want %s decoded at %s",
+					unwrap, i, ds.PresentationTime, ds.DecodingTime, ticksDuration(pts), ticksDuration(dts))
+			}
+		}
+	}
+}
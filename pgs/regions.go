@@ -0,0 +1,92 @@
+package pgs
+
+import "image"
+
+// TextRegions renders the display set and returns the bounding boxes of
+// its distinct blocks of content, such as the lines of a subtitle or a
+// sign shown with dialogue, from top to bottom. Pixels that are not
+// fully transparent are grouped into 8-connected components, then boxes
+// are merged while any two overlap vertically, counting boxes that touch
+// as overlapping, and are horizontally closer than the height of the
+// taller one, so the letters and words of a line join while lines
+// separated by a transparent row stay apart. Objects and the palette
+// must be defined in the display set itself.
+func (ds *DisplaySet) TextRegions() ([]image.Rectangle, error) {
+	img, err := ds.Render()
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := ds.ContentBounds()
+	if err != nil {
+		return nil, err
+	}
+	boxes := components(img, bounds)
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(boxes) && !merged; i++ {
+			for j := i + 1; j < len(boxes); j++ {
+				if joinRegions(boxes[i], boxes[j]) {
+					boxes[i] = boxes[i].Union(boxes[j])
+					boxes = append(boxes[:j], boxes[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+	// Sort by top, then left
+	for i := 1; i < len(boxes); i++ {
+		for j := i; j > 0 && (boxes[j].Min.Y < boxes[j-1].Min.Y ||
+			boxes[j].Min.Y == boxes[j-1].Min.Y && boxes[j].Min.X < boxes[j-1].Min.X); j-- {
+			boxes[j], boxes[j-1] = boxes[j-1], boxes[j]
+		}
+	}
+	return boxes, nil
+}
+
+// joinRegions reports whether two boxes belong to the same region.
+func joinRegions(a, b image.Rectangle) bool {
+	if a.Max.Y < b.Min.Y || b.Max.Y < a.Min.Y {
+		return false
+	}
+	gap := max(a.Min.X, b.Min.X) - min(a.Max.X, b.Max.X)
+	return gap < max(a.Dy(), b.Dy())
+}
+
+// components returns the bounding boxes of the 8-connected components of
+// pixels that are not fully transparent within r.
+func components(img *image.RGBA, r image.Rectangle) []image.Rectangle {
+	r = r.Intersect(img.Rect)
+	seen := make([]bool, r.Dx()*r.Dy())
+	index := func(p image.Point) int { return (p.Y-r.Min.Y)*r.Dx() + p.X - r.Min.X }
+	opaque := func(p image.Point) bool { return img.Pix[img.PixOffset(p.X, p.Y)+3] != 0 }
+	var boxes []image.Rectangle
+	var stack []image.Point
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := image.Point{x, y}
+			if seen[index(p)] || !opaque(p) {
+				continue
+			}
+			box := image.Rectangle{p, p.Add(image.Point{1, 1})}
+			seen[index(p)] = true
+			stack = append(stack[:0], p)
+			for len(stack) != 0 {
+				q := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				box = box.Union(image.Rectangle{q, q.Add(image.Point{1, 1})})
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						n := q.Add(image.Point{dx, dy})
+						if n.In(r) && !seen[index(n)] && opaque(n) {
+							seen[index(n)] = true
+							stack = append(stack, n)
+						}
+					}
+				}
+			}
+			boxes = append(boxes, box)
+		}
+	}
+	return boxes
+}
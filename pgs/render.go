@@ -0,0 +1,157 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// Render draws the composition objects of the display set onto a
+// transparent image with the dimensions of the video frame. Objects are
+// composited in order of their index in the composition, each blended
+// over the previous ones by its alpha. Objects and the palette defined
+// earlier in the epoch are resolved when the display set was read by a
+// Reader, as in ContentBounds.
+func (ds *DisplaySet) Render() (*image.RGBA, error) {
+	return ds.render(nil)
+}
+
+// RenderFallback renders the display set as Render, but with the
+// fallback palette, such as GrayscalePalette, when the display set does
+// not define its palette, as in damaged streams, so that the shapes of
+// the objects can be seen, though with the wrong colors.
+func (ds *DisplaySet) RenderFallback(fallback *Palette) (*image.RGBA, error) {
+	return ds.render(fallback)
+}
+
+func (ds *DisplaySet) render(fallback *Palette) (*image.RGBA, error) {
+	objects, p, err := ds.shown(fallback)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, int(ds.Width), int(ds.Height)))
+	for i, obj := range objects {
+		co := &ds.Objects[i]
+		src, err := obj.Convert(p)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", obj.ID, err)
+		}
+		r := co.rect(&obj.Image)
+		draw.Draw(img, r, src, r.Min.Sub(image.Point{int(co.X), int(co.Y)}), draw.Over)
+	}
+	return img, nil
+}
+
+// RenderScaled renders the display set as Render at scale times the
+// video dimensions, such as for thumbnails. Each object is resampled
+// with the filter before compositing, rather than the whole frame.
+func (ds *DisplaySet) RenderScaled(scale float64, f Filter) (*image.RGBA, error) {
+	if !(scale > 0) {
+		return nil, fmt.Errorf("invalid scale: %g", scale)
+	}
+	objects, p, err := ds.shown(nil)
+	if err != nil {
+		return nil, err
+	}
+	sc := func(v int) int { return int(math.Round(float64(v) * scale)) }
+	img := image.NewRGBA(image.Rect(0, 0, sc(int(ds.Width)), sc(int(ds.Height))))
+	for i, obj := range objects {
+		co := &ds.Objects[i]
+		src, err := obj.Convert(p)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", obj.ID, err)
+		}
+		if src.Rect.Empty() {
+			continue
+		}
+		dst, err := scalePaletted(src, p, max(sc(int(obj.Width)), 1), max(sc(int(obj.Height)), 1), f)
+		if err != nil {
+			return nil, err
+		}
+		r := co.rect(&obj.Image)
+		r = image.Rect(sc(r.Min.X), sc(r.Min.Y), sc(r.Max.X), sc(r.Max.Y))
+		draw.Draw(img, r, dst, r.Min.Sub(image.Point{sc(int(co.X)), sc(int(co.Y))}), draw.Over)
+	}
+	return img, nil
+}
+
+// RenderDebug renders the display set with overlays for diagnosing its
+// composition: window outlines in green labeled with W and the window ID,
+// object bounds at their composition position in red labeled with O and
+// the object ID, and the crop of cropped objects in yellow labeled
+// with C. Overlays are drawn even where they fall outside of the frame
+// edges, so misplaced objects show as clipped outlines.
+func (ds *DisplaySet) RenderDebug() (*image.RGBA, error) {
+	objects, _, err := ds.shown(nil)
+	if err != nil {
+		return nil, err
+	}
+	img, err := ds.Render()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		green  = color.RGBA{0, 0xff, 0, 0xff}
+		red    = color.RGBA{0xff, 0, 0, 0xff}
+		yellow = color.RGBA{0xff, 0xff, 0, 0xff}
+	)
+	for _, w := range ds.Windows {
+		r := w.Rect()
+		drawOutline(img, r, green)
+		drawLabel(img, r.Min.Add(image.Point{2, 2}), fmt.Sprintf("W%d", w.ID), green)
+	}
+	for i, obj := range objects {
+		co := &ds.Objects[i]
+		r := image.Rect(int(co.X), int(co.Y), int(co.X)+int(obj.Width), int(co.Y)+int(obj.Height))
+		drawOutline(img, r, red)
+		drawLabel(img, r.Min.Add(image.Point{2, 2}), fmt.Sprintf("O%d", co.ObjectID), red)
+		if co.Crop != nil {
+			cr := co.Crop.Rect()
+			drawOutline(img, cr, yellow)
+			drawLabel(img, image.Point{cr.Max.X - glyphWidth - 2, cr.Min.Y + 2}, "C", yellow)
+		}
+	}
+	return img, nil
+}
+
+// drawOutline draws the one pixel border inside r in color c.
+func drawOutline(img *image.RGBA, r image.Rectangle, c color.Color) {
+	if r.Empty() {
+		return
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X-1, y, c)
+	}
+}
+
+// RenderAll reads the stream and calls fn with each rendered display set
+// and its index, so that only one frame is held in memory at a time.
+// Display sets showing objects defined earlier in the epoch are rendered
+// with those definitions, as in Render. An error returned by fn stops
+// rendering and is returned.
+func RenderAll(r *Reader, fn func(i int, img *image.RGBA) error) error {
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		img, err := ds.Render()
+		if err != nil {
+			return fmt.Errorf("display set %d: %w", i, err)
+		}
+		if err := fn(i, img); err != nil {
+			return err
+		}
+	}
+}
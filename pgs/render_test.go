@@ -0,0 +1,138 @@
+package pgs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+// reusedObjectStream returns a stream with an EpochStart defining two
+// overlapping objects and showing the first, followed by a Normal display
+// set showing both without defining them again.
+func reusedObjectStream(t *testing.T) []byte {
+	t.Helper()
+	p := color.Palette{color.Transparent, color.White, color.NRGBA{0, 0, 0, 0x80}}
+	white := image.NewPaletted(image.Rect(0, 0, 2, 2), p)
+	shade := image.NewPaletted(image.Rect(0, 0, 2, 2), p)
+	for i := range white.Pix {
+		white.Pix[i], shade.Pix[i] = 1, 2
+	}
+	show, err := NewDisplaySet(white, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rle, err := EncodeRLE(shade)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := *show.Object
+	obj.ID, obj.Image, obj.DataLen = 1, *rle, len(rle.Data)
+	show.ExtraObjects = []Object{obj}
+	both := NewClearDisplaySet(2*time.Second, 0)
+	both.Objects = []CompositionObject{{ObjectID: 0}, {ObjectID: 1, X: 1}}
+	stream := []DisplaySet{*show, *both, *NewClearDisplaySet(3*time.Second, 0)}
+	for i := range stream {
+		stream[i].Width, stream[i].Height = 4, 2
+		stream[i].CompositionNumber = uint16(i)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRenderReusedObjects(t *testing.T) {
+	stream, err := NewReader(bytes.NewReader(reusedObjectStream(t))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := stream[1].Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The shade is blended over the white object where they overlap and
+	// over the transparent frame elsewhere
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("white object pixel is %v", got)
+	}
+	if got := img.RGBAAt(1, 0); got.A != 0xff || got.R == 0 || got.R == 0xff {
+		t.Errorf("overlapping pixel is %v, want opaque gray", got)
+	}
+	if got := img.RGBAAt(2, 0); got.A == 0 || got.A == 0xff || got.R != 0 {
+		t.Errorf("shade pixel is %v, want translucent black", got)
+	}
+	if got := img.RGBAAt(3, 0); got.A != 0 {
+		t.Errorf("uncovered pixel is %v, want transparent", got)
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	var shown []int
+	err := RenderAll(NewReader(bytes.NewReader(reusedObjectStream(t))), func(i int, img *image.RGBA) error {
+		if img.RGBAAt(0, 0).A != 0 {
+			shown = append(shown, i)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shown) != 2 || shown[0] != 0 || shown[1] != 1 {
+		t.Errorf("display sets %v shown, want [0 1]", shown)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = RenderAll(NewReader(bytes.NewReader(reusedObjectStream(t))), func(i int, img *image.RGBA) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("RenderAll returned %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestRenderScaledReusedObjects(t *testing.T) {
+	stream, err := NewReader(bytes.NewReader(reusedObjectStream(t))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := stream[1].RenderScaled(2, NearestNeighbor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Rect != image.Rect(0, 0, 8, 4) {
+		t.Fatalf("rendered %v, want 8x4", img.Rect)
+	}
+	full, err := stream[1].Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 4 {
+		for x := range 8 {
+			if got, want := img.RGBAAt(x, y), full.RGBAAt(x/2, y/2); got != want {
+				t.Errorf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderDebugReusedObjects(t *testing.T) {
+	stream, err := NewReader(bytes.NewReader(reusedObjectStream(t))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := stream[1].RenderDebug()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The outline of the second object, defined by the first display set,
+	// is drawn over the transparent frame
+	if got := img.RGBAAt(2, 1); got != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("object outline pixel is %v, want red", got)
+	}
+}
@@ -0,0 +1,196 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// decodeRuns walks the run-length encoded data of the image and calls fn
+// for each run of n pixels in palette entry c starting at (x, y).
+func (img *Image) decodeRuns(fn func(x, y, n int, c uint8)) error {
+	return img.walkRuns(false, fn)
+}
+
+// walkRuns walks the runs as in decodeRuns. When lenient, lines of the
+// wrong width end at their end of line marker, pixels beyond the width or
+// height are dropped, and missing end of line markers and lines are
+// accepted, rather than being errors.
+func (img *Image) walkRuns(lenient bool, fn func(x, y, n int, c uint8)) error {
+	if lenient {
+		strict := fn
+		w, h := int(img.Width), int(img.Height)
+		fn = func(x, y, n int, c uint8) {
+			if x+n > w {
+				n = w - x
+			}
+			if y < h && n > 0 {
+				strict(x, y, n, c)
+			}
+		}
+	}
+	d := img.Data
+	x, y := 0, 0
+	for i := 0; i < len(d); {
+		if d[i] != 0 { // CCCCCCCC - One pixel in color C
+			fn(x, y, 1, d[i])
+			i++
+			x++
+			continue
+		}
+		if i+1 >= len(d) {
+			return errors.New("truncated run")
+		}
+		var c uint8
+		var l int
+
+		hd1, ld1 := d[i+1]&0xc0, d[i+1]&0x3f
+		switch hd1 {
+		case 0x00:
+			i += 2
+			// 00000000 00000000 - End of line
+			if ld1 == 0 {
+				if x != int(img.Width) && !lenient {
+					return fmt.Errorf("line %d has width %d instead of %d", y, x, img.Width)
+				}
+				x = 0
+				y++
+				continue
+			}
+			// 00000000 00LLLLLL - L pixels in color 0
+			l = int(ld1)
+		// 00000000 01LLLLLL LLLLLLLL - L pixels in color 0
+		case 0x40:
+			if i+2 >= len(d) {
+				return errors.New("truncated run")
+			}
+			l = int(ld1)<<8 | int(d[i+2])
+			i += 3
+		// 00000000 10LLLLLL CCCCCCCC - L pixels in color C
+		case 0x80:
+			if i+2 >= len(d) {
+				return errors.New("truncated run")
+			}
+			l = int(ld1)
+			c = d[i+2]
+			i += 3
+		// 00000000 11LLLLLL LLLLLLLL CCCCCCCC - L pixels in color C
+		case 0xc0:
+			if i+3 >= len(d) {
+				return errors.New("truncated run")
+			}
+			l = int(ld1)<<8 | int(d[i+2])
+			c = d[i+3]
+			i += 4
+		default:
+			return fmt.Errorf("unhandled run type: 0x%x", hd1)
+		}
+		fn(x, y, l, c)
+		x += l
+	}
+	if lenient {
+		return nil
+	}
+	if x != 0 {
+		return fmt.Errorf("line %d with width %d not terminated", y, x)
+	}
+	if y != int(img.Height) {
+		return fmt.Errorf("image has height %d instead of %d", y, img.Height)
+	}
+	return nil
+}
+
+// EncodeRLE run-length encodes the color indices of the paletted image,
+// which are the IDs of the palette entries. The encoding is canonical, so
+// the same image always yields identical bytes:
+//
+//   - Each line is split greedily into maximal runs of one color, with
+//     runs longer than 16383 pixels split into full runs of 16383 and a
+//     remainder.
+//   - Each run uses the shortest form: one or two pixels of a nonzero
+//     color as literal bytes, and otherwise a short form for fewer than
+//     64 pixels and a long form for 64 or more, with the color omitted
+//     for 0.
+//   - Each line, including the last, ends with an end of line marker.
+func EncodeRLE(pimg *image.Paletted) (*Image, error) {
+	r := pimg.Rect
+	if r.Dx() > 0xffff || r.Dy() > 0xffff {
+		return nil, fmt.Errorf("image dimensions overflow: %dx%d", r.Dx(), r.Dy())
+	}
+	var d []byte
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		line := pimg.Pix[pimg.PixOffset(r.Min.X, y):pimg.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(line); {
+			c := line[i]
+			l := 1
+			for i+l < len(line) && line[i+l] == c && l < 0x3fff {
+				l++
+			}
+			d = appendRun(d, c, l)
+			i += l
+		}
+		d = append(d, 0, 0) // End of line
+	}
+	return &Image{
+		Width:  uint16(r.Dx()),
+		Height: uint16(r.Dy()),
+		Data:   d,
+	}, nil
+}
+
+// EncodeImage run length encodes an image of any color model, with a
+// palette of its distinct colors in order of first appearance. Fully
+// transparent pixels, of any color, take entry 0, which is the most
+// compact to encode. Images with more than 255 opaque or translucent
+// colors must first be quantized, such as by drawing onto an
+// image.Paletted with draw.FloydSteinberg.
+func EncodeImage(src image.Image) (*Image, *Palette, error) {
+	r := src.Bounds()
+	pimg := image.NewPaletted(r, nil)
+	p := &Palette{Entries: []PaletteEntry{{ID: 0}}}
+	ids := make(map[color.NYCbCrA]uint8)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NYCbCrAModel.Convert(src.At(x, y)).(color.NYCbCrA)
+			if c.A == 0 {
+				continue
+			}
+			id, ok := ids[c]
+			if !ok {
+				if len(p.Entries) == 256 {
+					return nil, nil, errors.New("image has more than 255 colors")
+				}
+				id = uint8(len(p.Entries))
+				ids[c] = id
+				p.Entries = append(p.Entries, PaletteEntry{ID: id, NYCbCrA: c})
+			}
+			pimg.Pix[pimg.PixOffset(x, y)] = id
+		}
+	}
+	img, err := EncodeRLE(pimg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, p, nil
+}
+
+// appendRun appends the shortest encoding of a run of l pixels in color
+// c, for 0 < l < 0x4000.
+func appendRun(d []byte, c uint8, l int) []byte {
+	switch {
+	case c != 0 && l <= 2:
+		for i := 0; i < l; i++ {
+			d = append(d, c) // CCCCCCCC
+		}
+		return d
+	case c == 0 && l < 0x40:
+		return append(d, 0, uint8(l)) // 00000000 00LLLLLL
+	case c == 0:
+		return append(d, 0, 0x40|uint8(l>>8), uint8(l)) // 00000000 01LLLLLL LLLLLLLL
+	case l < 0x40:
+		return append(d, 0, 0x80|uint8(l), c) // 00000000 10LLLLLL CCCCCCCC
+	default:
+		return append(d, 0, 0xc0|uint8(l>>8), uint8(l), c) // 00000000 11LLLLLL LLLLLLLL CCCCCCCC
+	}
+}
@@ -0,0 +1,102 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeRLECanonical(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 70, 2), make(color.Palette, 3))
+	for x := 0; x < 70; x++ {
+		switch {
+		case x < 2:
+			img.SetColorIndex(x, 0, 1)
+		case x < 5:
+			img.SetColorIndex(x, 0, 2)
+		}
+		img.SetColorIndex(x, 1, 2)
+	}
+	rle, err := EncodeRLE(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 1, 0, 0x83, 2, 0, 0x40, 65, 0, 0, // 2×1, 3×2, 65×0
+		0, 0xc0, 70, 2, 0, 0, // 70×2
+	}
+	if !bytes.Equal(rle.Data, want) {
+		t.Fatalf("encoded % x, want % x", rle.Data, want)
+	}
+
+	// Decoding and encoding again yields identical bytes
+	dec, err := rle.Convert(&Palette{Entries: []PaletteEntry{{ID: 0}, {ID: 1}, {ID: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := EncodeRLE(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Data, rle.Data) {
+		t.Errorf("re-encoded % x, want % x", again.Data, rle.Data)
+	}
+}
+
+func TestEncodeImage(t *testing.T) {
+	colors := color.Palette{
+		color.NYCbCrA{YCbCr: color.YCbCr{Y: 16, Cb: 128, Cr: 128}, A: 0}, // Transparent, but not zero
+		color.NYCbCrA{YCbCr: color.YCbCr{Y: 235, Cb: 128, Cr: 128}, A: 0xff},
+		color.NYCbCrA{YCbCr: color.YCbCr{Y: 81, Cb: 90, Cr: 240}, A: 0x80},
+	}
+	src := image.NewPaletted(image.Rect(2, 3, 12, 8), colors)
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i / 7 % 3)
+	}
+	img, p, err := EncodeImage(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Entries) != 3 {
+		t.Errorf("got %d palette entries, want 3", len(p.Entries))
+	}
+	got, err := img.Convert(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := src.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			want := src.At(x, y).(color.NYCbCrA)
+			c := got.ColorIndexAt(x-r.Min.X, y-r.Min.Y)
+			if want.A == 0 && c != 0 || want.A != 0 && got.At(x-r.Min.X, y-r.Min.Y) != want {
+				t.Fatalf("pixel (%d, %d): got %v, want %v", x, y, got.At(x-r.Min.X, y-r.Min.Y), want)
+			}
+		}
+	}
+}
+
+func TestObjectDecodeInto(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), make(color.Palette, 3))
+	img.Pix = []uint8{0, 1, 1, 0, 2, 2, 2, 2}
+	rle, err := EncodeRLE(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &Object{First: true, Last: true, Image: *rle}
+	dst := make([]uint8, obj.DecodedSize())
+	p := &Palette{Entries: []PaletteEntry{{ID: 0}, {ID: 1}, {ID: 2}}}
+	if err := obj.DecodeInto(dst, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, img.Pix) {
+		t.Errorf("decoded %v, want %v", dst, img.Pix)
+	}
+	if err := obj.DecodeInto(dst[:7], p); err == nil {
+		t.Error("decoded into a buffer too small")
+	}
+	if err := obj.DecodeInto(dst, &Palette{Entries: p.Entries[:2]}); err == nil {
+		t.Error("decoded with palette entry 2 undefined")
+	}
+}
@@ -0,0 +1,60 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"time"
+)
+
+// Violation is a display set with content outside of an allowed area.
+type Violation struct {
+	Time       time.Duration   // Presentation time of the display set
+	Bounds     image.Rectangle // Content bounds in frame coordinates
+	Safe       image.Rectangle // Allowed area in frame coordinates
+	DisplaySet *DisplaySet
+}
+
+// TitleSafeArea returns the area of a width by height frame inset on
+// each side by insetPercent of the dimension.
+func TitleSafeArea(width, height int, insetPercent float64) image.Rectangle {
+	dx := int(float64(width) * insetPercent / 100)
+	dy := int(float64(height) * insetPercent / 100)
+	return image.Rect(dx, dy, width-dx, height-dy)
+}
+
+// TitleSafeViolations reads the stream and reports the display sets with
+// content bounds extending beyond the title-safe area, inset on each
+// side by insetPercent of the frame size, such as 5 for broadcast. Such
+// content may be cut off on overscanning displays. Only display sets
+// defining their object and palette can be checked; others are skipped.
+func TitleSafeViolations(r *Reader, insetPercent float64) ([]Violation, error) {
+	if insetPercent < 0 || insetPercent >= 50 {
+		return nil, fmt.Errorf("inset out of range: %g%%", insetPercent)
+	}
+	var violations []Violation
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return violations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.IsClear() || ds.Object == nil || ds.Palette == nil {
+			continue
+		}
+		bounds, err := ds.ContentBounds()
+		if err != nil {
+			return nil, fmt.Errorf("display set %d: %w", i, err)
+		}
+		if bounds.Empty() {
+			continue
+		}
+		w, h := ds.FrameSize()
+		safe := TitleSafeArea(w, h, insetPercent)
+		if !bounds.In(safe) {
+			violations = append(violations, Violation{ds.PresentationTime, bounds, safe, ds})
+		}
+	}
+}
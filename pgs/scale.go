@@ -0,0 +1,223 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Filter is a resampling filter for scaling images.
+type Filter uint8
+
+const (
+	// Bilinear weights source pixels by a triangle filter that widens
+	// to cover all source pixels when downscaling, blending in
+	// premultiplied RGBA, then maps each pixel to the nearest color in
+	// the palette. Edges stay anti-aliased, at the cost of only being
+	// able to use colors already in the palette.
+	Bilinear Filter = iota
+	// NearestNeighbor picks the closest source pixel, so the image keeps
+	// exactly the palette entries of the original.
+	NearestNeighbor
+)
+
+// Scale decodes the image, resamples it to the given dimensions with the
+// filter, and encodes it again.
+func (img *Image) Scale(p *Palette, width, height int, f Filter) (*Image, error) {
+	if width <= 0 || height <= 0 || width > 0xffff || height > 0xffff {
+		return nil, fmt.Errorf("invalid dimensions: %dx%d", width, height)
+	}
+	src, err := img.Convert(p)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := scalePaletted(src, p, width, height, f)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeRLE(dst)
+}
+
+// scalePaletted resamples src, converted with p, to the given dimensions.
+func scalePaletted(src *image.Paletted, p *Palette, width, height int, f Filter) (*image.Paletted, error) {
+	switch f {
+	case NearestNeighbor:
+		return scaleNearest(src, width, height), nil
+	case Bilinear:
+		return scaleBilinear(src, p, width, height), nil
+	}
+	return nil, fmt.Errorf("unrecognized filter: %d", f)
+}
+
+func scaleNearest(src *image.Paletted, width, height int) *image.Paletted {
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), src.Palette)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	for y := 0; y < height; y++ {
+		sy := (2*y + 1) * sh / (2 * height)
+		for x := 0; x < width; x++ {
+			sx := (2*x + 1) * sw / (2 * width)
+			dst.Pix[dst.PixOffset(x, y)] = src.Pix[src.PixOffset(sx, sy)]
+		}
+	}
+	return dst
+}
+
+// rgba is a premultiplied color with float components in [0, 0xffff].
+type rgba [4]float64
+
+// scaleBilinear resamples src, converted with p, mapping blended colors
+// back to the entries defined in p, rather than to the transparent
+// entries padding the palette of src.
+func scaleBilinear(src *image.Paletted, p *Palette, width, height int) *image.Paletted {
+	colors := make([]rgba, len(src.Palette))
+	for i, c := range src.Palette {
+		r, g, b, a := c.RGBA()
+		colors[i] = rgba{float64(r), float64(g), float64(b), float64(a)}
+	}
+	ids := make([]uint8, 0, len(p.Entries))
+	for _, e := range p.Entries {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) == 0 {
+		for i := range colors {
+			ids = append(ids, uint8(i))
+		}
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	pix := make([]rgba, sw*sh)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			pix[y*sw+x] = colors[src.Pix[src.PixOffset(x, y)]]
+		}
+	}
+	pix = resampleX(pix, sw, sh, width)
+	pix = resampleY(pix, width, sh, height)
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), src.Palette)
+	nearest := make(map[rgba]uint8)
+	for i, c := range pix {
+		ci, ok := nearest[c]
+		if !ok {
+			ci = nearestColor(colors, ids, c)
+			nearest[c] = ci
+		}
+		dst.Pix[i] = ci
+	}
+	return dst
+}
+
+// resampleX scales the rows of a w×h image to width m.
+func resampleX(pix []rgba, w, h, m int) []rgba {
+	out := make([]rgba, m*h)
+	weights := filterWeights(w, m)
+	for y := 0; y < h; y++ {
+		for x, ws := range weights {
+			out[y*m+x] = blend(pix, ws, y*w, 1)
+		}
+	}
+	return out
+}
+
+// resampleY scales the columns of a w×h image to height m.
+func resampleY(pix []rgba, w, h, m int) []rgba {
+	out := make([]rgba, w*m)
+	weights := filterWeights(h, m)
+	for x := 0; x < w; x++ {
+		for y, ws := range weights {
+			out[y*w+x] = blend(pix, ws, x, w)
+		}
+	}
+	return out
+}
+
+type weight struct {
+	i int
+	w float64
+}
+
+// filterWeights computes the triangle filter weights of the n source
+// samples for each of m destination samples. The filter radius is at
+// least one source sample and widens by the scale factor when
+// downscaling.
+func filterWeights(n, m int) [][]weight {
+	scale := float64(n) / float64(m)
+	radius := math.Max(1, scale)
+	weights := make([][]weight, m)
+	for i := range weights {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := int(math.Ceil(center - radius))
+		hi := int(math.Floor(center + radius))
+		var ws []weight
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			w := 1 - math.Abs(float64(j)-center)/radius
+			if w <= 0 {
+				continue
+			}
+			k := j
+			if k < 0 {
+				k = 0
+			} else if k >= n {
+				k = n - 1
+			}
+			ws = append(ws, weight{k, w})
+			sum += w
+		}
+		for j := range ws {
+			ws[j].w /= sum
+		}
+		weights[i] = ws
+	}
+	return weights
+}
+
+// blend sums the weighted samples at offset + i*stride.
+func blend(pix []rgba, ws []weight, offset, stride int) rgba {
+	var c rgba
+	for _, w := range ws {
+		s := pix[offset+w.i*stride]
+		for k := range c {
+			c[k] += s[k] * w.w
+		}
+	}
+	return c
+}
+
+// nearestColor returns the index of the closest color among those with
+// the given indices by squared distance.
+func nearestColor(colors []rgba, ids []uint8, c rgba) uint8 {
+	best, bestDist := ids[0], math.Inf(1)
+	for _, i := range ids {
+		var dist float64
+		for k := range c {
+			d := c[k] - colors[i][k]
+			dist += d * d
+		}
+		if dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// scaleRGBA resamples the image to the given dimensions with the
+// bilinear filter.
+func scaleRGBA(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	pix := make([]rgba, sw*sh)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			i := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+			s := src.Pix[i : i+4]
+			pix[y*sw+x] = rgba{float64(s[0]) * 0x101, float64(s[1]) * 0x101, float64(s[2]) * 0x101, float64(s[3]) * 0x101}
+		}
+	}
+	pix = resampleX(pix, sw, sh, width)
+	pix = resampleY(pix, width, sh, height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, c := range pix {
+		for k := range c {
+			dst.Pix[4*i+k] = uint8(math.Round(c[k] / 0x101))
+		}
+	}
+	return dst
+}
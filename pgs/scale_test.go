@@ -0,0 +1,37 @@
+package pgs
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestScaleDefinedEntries(t *testing.T) {
+	// Entries 0 to 2 and 4 pad the palette when decoded
+	p := &Palette{Entries: []PaletteEntry{
+		{ID: 3, NYCbCrA: color.NYCbCrA{YCbCr: color.YCbCr{Y: 16, Cb: 128, Cr: 128}}},
+		{ID: 5, NYCbCrA: color.NYCbCrA{YCbCr: color.YCbCr{Y: 235, Cb: 128, Cr: 128}, A: 0xff}},
+	}}
+	src := image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Transparent, color.Transparent, color.Transparent, color.Transparent, color.Transparent, color.White})
+	for i := range src.Pix {
+		src.Pix[i] = 3
+	}
+	src.SetColorIndex(4, 4, 5)
+	img, err := EncodeRLE(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := img.Scale(p, 5, 5, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used, err := scaled.UsedIndices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := range used {
+		if id != 3 && id != 5 {
+			t.Errorf("scaled image uses undefined entry %d", id)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pgs
+
+import "io"
+
+// Scanner reads display sets in the style of bufio.Scanner. Successive
+// calls to Scan step through the display sets of the stream.
+type Scanner struct {
+	r   *Reader
+	ds  *DisplaySet
+	err error
+}
+
+func NewScanner(r *Reader) *Scanner {
+	return &Scanner{r: r}
+}
+
+// Scan advances to the next display set, which is then available through
+// DisplaySet. It returns false when the scan stops, either by reaching
+// the end of the stream or an error. After Scan returns false, Err
+// returns any error that occurred, except for io.EOF.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	ds, err := s.r.Read()
+	if err != nil {
+		s.ds = nil
+		s.err = err
+		return false
+	}
+	s.ds = ds
+	return true
+}
+
+// DisplaySet returns the most recent display set read by Scan.
+func (s *Scanner) DisplaySet() *DisplaySet {
+	return s.ds
+}
+
+// Err returns the first non-EOF error encountered by the Scanner.
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,304 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+const headerSize = 13
+
+// readRawSegment reads a segment header and the payload of the size it
+// declares, without parsing the payload. The returned bytes include the
+// header.
+func readRawSegment(r io.Reader) (*Header, []byte, error) {
+	var buf bytes.Buffer
+	var h Header
+	if err := binary.Read(io.TeeReader(r, &buf), binary.BigEndian, &h); err != nil {
+		return nil, nil, err
+	}
+	if err := h.validate(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.CopyN(&buf, r, int64(h.SegmentSize)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	return &h, buf.Bytes(), nil
+}
+
+// ReadDisplaySetBytes reads the raw bytes of one complete display set,
+// from its PCS to its END segment, without decoding the payloads, so that
+// it can be passed through unchanged. It returns io.EOF when there are no
+// more display sets.
+func ReadDisplaySetBytes(r io.Reader) ([]byte, error) {
+	var ds []byte
+	for {
+		h, b, err := readRawSegment(r)
+		if err == io.EOF && len(ds) == 0 {
+			return nil, io.EOF
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("segment: %w", err)
+		}
+		if len(ds) == 0 && h.SegmentType != PCSType {
+			return nil, fmt.Errorf("segment not PCS: %s", h.SegmentType)
+		}
+		if len(ds) != 0 && h.SegmentType == PCSType {
+			return nil, errors.New("presentation composition not ended")
+		}
+		ds = append(ds, b...)
+		if h.SegmentType == ENDType {
+			return ds, nil
+		}
+	}
+}
+
+// FixSegmentSizes copies a stream, recomputing the size in each segment
+// header from the structure of its payload, for streams whose sizes
+// disagree with their payloads and are rejected by Reader. PDS sizes
+// cannot be derived from the payload, so are kept. Objects split into
+// fragments over several segments declare only their total length, so
+// the sizes of fragments before the last are kept when they fit the
+// object and the segment, or else taken to fill the segment, and the
+// last fragment has the rest of the data. It returns the number of
+// segments corrected.
+func FixSegmentSizes(r io.Reader, w io.Writer) (int, error) {
+	fixed := 0
+	rest := 0 // Object data left for the fragments of an object
+	for i := 0; ; i++ {
+		var h Header
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			if err == io.EOF {
+				return fixed, nil
+			}
+			return fixed, fmt.Errorf("segment %d header: %w", i, err)
+		}
+		if h.MagicNumber != 0x5047 {
+			return fixed, fmt.Errorf(`segment %d: magic number not "PG" 0x5047: %x`, i, h.MagicNumber)
+		}
+		payload, err := readPayload(r, &h, &rest)
+		if err != nil {
+			return fixed, fmt.Errorf("segment %d: %s: %w", i, h.SegmentType, err)
+		}
+		if int(h.SegmentSize) != len(payload) {
+			if len(payload) > 0xffff {
+				return fixed, fmt.Errorf("segment %d: %s: size overflow: %d", i, h.SegmentType, len(payload))
+			}
+			h.SegmentSize = uint16(len(payload))
+			fixed++
+		}
+		if err := binary.Write(w, binary.BigEndian, &h); err != nil {
+			return fixed, err
+		}
+		if _, err := w.Write(payload); err != nil {
+			return fixed, err
+		}
+	}
+}
+
+// readPayload reads a segment payload, using its structure rather than
+// the size in the header to determine its length, except for PDS and
+// fragments of objects, as described by FixSegmentSizes. The object data
+// left for the following fragments is kept in rest.
+func readPayload(r io.Reader, h *Header, rest *int) ([]byte, error) {
+	var buf bytes.Buffer
+	tr := io.TeeReader(r, &buf)
+	read := func(n int) error {
+		_, err := io.CopyN(io.Discard, tr, int64(n))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	switch h.SegmentType {
+	case PCSType:
+		var pcs pcs
+		if err := binary.Read(tr, binary.BigEndian, &pcs); err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(pcs.ObjectCount); i++ {
+			var obj pcsObject
+			if err := binary.Read(tr, binary.BigEndian, &obj); err != nil {
+				return nil, err
+			}
+			if obj.ObjectCropped&croppedOn != 0 {
+				if err := read(8); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case WDSType:
+		var wds wds
+		if err := binary.Read(tr, binary.BigEndian, &wds); err != nil {
+			return nil, err
+		}
+		if err := read(int(wds.WindowCount) * 9); err != nil {
+			return nil, err
+		}
+	case PDSType:
+		if err := read(int(h.SegmentSize)); err != nil {
+			return nil, err
+		}
+	case ODSType:
+		var frag odsFragment
+		if err := binary.Read(tr, binary.BigEndian, &frag); err != nil {
+			return nil, err
+		}
+		last := frag.SequenceFlag&lastInSequence != 0
+		if *rest > 0 && frag.SequenceFlag&firstInSequence == 0 {
+			n := *rest
+			if !last {
+				n = fragmentLen(int(h.SegmentSize)-4, *rest, maxFragment)
+			}
+			if n > maxFragment {
+				return nil, fmt.Errorf("object fragment of %d bytes exceeds segment", n)
+			}
+			*rest -= n
+			if err := read(n); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+		var size odsSize
+		if err := binary.Read(tr, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size.ObjectDataLength.Int() < 4 {
+			return nil, errors.New("data length excludes width and height")
+		}
+		dataLen := size.ObjectDataLength.Int() - 4
+		n := dataLen
+		if !last {
+			n = fragmentLen(int(h.SegmentSize)-11, dataLen, maxFirstFragment)
+		}
+		if n > maxFirstFragment {
+			return nil, fmt.Errorf("object data of %d bytes exceeds segment", n)
+		}
+		*rest = dataLen - n
+		if err := read(n); err != nil {
+			return nil, err
+		}
+	case ENDType:
+	default:
+		return nil, fmt.Errorf("unrecognized segment type: 0x%x", h.SegmentType)
+	}
+	return buf.Bytes(), nil
+}
+
+// fragmentLen returns the length of object data in a fragment before the
+// last, which is the length declared by its segment size, if it fits the
+// data left and the segment, or else fills the segment.
+func fragmentLen(declared, rest, max int) int {
+	if declared >= 0 && declared <= rest && declared <= max {
+		return declared
+	}
+	return min(rest, max)
+}
+
+// ByteFootprint reads the stream and returns the total bytes, headers
+// included, taken by segments of each type. Payloads are skipped as in
+// ScanHeaders, without being read into memory or decoded.
+func ByteFootprint(r io.Reader) (map[SegmentType]int64, error) {
+	footprint := make(map[SegmentType]int64)
+	err := ScanHeaders(r, func(typ SegmentType, pts, dts time.Duration, size uint16, offset int64) error {
+		footprint[typ] += headerSize + int64(size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return footprint, nil
+}
+
+// SegmentChecksum identifies the payload of a segment for comparing
+// streams at the segment level.
+type SegmentChecksum struct {
+	Type   SegmentType
+	Offset int64  // Offset of the segment header in the stream
+	CRC    uint32 // IEEE CRC-32 of the payload
+}
+
+// SegmentChecksums reads the stream and returns the checksum of each
+// segment payload, without decoding it, so that two streams can be
+// compared cheaply to find which segments differ. Headers are excluded,
+// so segments differing only in timestamps have equal checksums.
+func SegmentChecksums(r io.Reader) ([]SegmentChecksum, error) {
+	var sums []SegmentChecksum
+	err := walkHeaders(r, func(h *Header, offset int64, payload io.Reader) error {
+		crc := crc32.NewIEEE()
+		if _, err := io.Copy(crc, payload); err != nil {
+			return err
+		}
+		sums = append(sums, SegmentChecksum{h.SegmentType, offset, crc.Sum32()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// ScanHeaders reads only the segment headers of the stream, skipping the
+// payloads, and calls fn with the type, times, payload size, and offset
+// of the header of each segment, for building indexes over large files.
+// Payloads are skipped by seeking when r is an io.Seeker, in which case
+// a truncated final payload is not detected. Times are not unwrapped
+// across wraparounds of the clock. Returning an error from fn stops the
+// scan with that error.
+func ScanHeaders(r io.Reader, fn func(typ SegmentType, pts, dts time.Duration, size uint16, offset int64) error) error {
+	return walkHeaders(r, func(h *Header, offset int64, payload io.Reader) error {
+		return fn(h.SegmentType, h.PresentationTime.Duration(), h.DecodingTime.Duration(), h.SegmentSize, offset)
+	})
+}
+
+// walkHeaders reads the segment headers of the stream and calls fn with
+// each header, its offset, and a reader of its payload. The part of the
+// payload not read by fn is skipped, by seeking when r is an io.Seeker
+// and fn read none of it. Errors from fn are returned as they are.
+func walkHeaders(r io.Reader, fn func(h *Header, offset int64, payload io.Reader) error) error {
+	seeker, _ := r.(io.Seeker)
+	var buf [headerSize]byte
+	var offset int64
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("segment %d: %w", i, err)
+		}
+		h := Header{
+			MagicNumber:      binary.BigEndian.Uint16(buf[0:]),
+			PresentationTime: Timestamp(binary.BigEndian.Uint32(buf[2:])),
+			DecodingTime:     Timestamp(binary.BigEndian.Uint32(buf[6:])),
+			SegmentType:      SegmentType(buf[10]),
+			SegmentSize:      binary.BigEndian.Uint16(buf[11:]),
+		}
+		if err := h.validate(); err != nil {
+			return fmt.Errorf("segment %d: %w", i, err)
+		}
+		payload := &io.LimitedReader{R: r, N: int64(h.SegmentSize)}
+		if err := fn(&h, offset, payload); err != nil {
+			return err
+		}
+		var err error
+		if seeker != nil && payload.N == int64(h.SegmentSize) {
+			_, err = seeker.Seek(payload.N, io.SeekCurrent)
+		} else if _, err = io.CopyN(io.Discard, payload, payload.N); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", i, err)
+		}
+		offset += headerSize + int64(h.SegmentSize)
+	}
+}
@@ -0,0 +1,64 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestFixSegmentSizesFragments(t *testing.T) {
+	// Noise encodes to more than 64 KB of data, so the object is split
+	img := image.NewPaletted(image.Rect(0, 0, 400, 400), color.Palette{color.Transparent, color.White})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7 % 3 % 2)
+	}
+	ds, err := NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Object.Data) <= 0xffff {
+		t.Fatalf("object data of %d bytes fits in one segment", len(ds.Object.Data))
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).Write(ds); err != nil {
+		t.Fatal(err)
+	}
+	sup := b.Bytes()
+
+	var out bytes.Buffer
+	fixed, err := FixSegmentSizes(bytes.NewReader(sup), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 0 || !bytes.Equal(out.Bytes(), sup) {
+		t.Errorf("valid stream changed: %d segments fixed", fixed)
+	}
+
+	// Corrupt the size of the last fragment
+	bad := append([]byte(nil), sup...)
+	var last int
+	for off := 0; off < len(bad); off += headerSize + int(binary.BigEndian.Uint16(bad[off+11:])) {
+		if SegmentType(bad[off+10]) == ODSType {
+			last = off
+		}
+	}
+	binary.BigEndian.PutUint16(bad[last+11:], 3)
+	out.Reset()
+	fixed, err = FixSegmentSizes(bytes.NewReader(bad), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 1 || !bytes.Equal(out.Bytes(), sup) {
+		t.Errorf("got %d segments fixed, want 1", fixed)
+	}
+	got, err := NewReader(&out).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Object.Data, ds.Object.Data) {
+		t.Error("object data not round tripped")
+	}
+}
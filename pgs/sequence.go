@@ -0,0 +1,51 @@
+package pgs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type sequencePart struct {
+	r      io.Reader
+	offset time.Duration
+}
+
+// OpenSequence opens the files and returns a Reader that reads them in
+// order as one stream, along with a function to close the files. When
+// durations is non-nil, it has the duration of the video for each file
+// and the timestamps of each file are offset by the total duration of
+// the files before it.
+func OpenSequence(paths []string, durations []time.Duration) (*Reader, func() error, error) {
+	if durations != nil && len(durations) != len(paths) {
+		return nil, nil, fmt.Errorf("%d durations given for %d files", len(durations), len(paths))
+	}
+	files := make([]*os.File, 0, len(paths))
+	closeAll := func() error {
+		var err error
+		for _, f := range files {
+			if err1 := f.Close(); err1 != nil && err == nil {
+				err = err1
+			}
+		}
+		return err
+	}
+	parts := make([]sequencePart, len(paths))
+	var offset time.Duration
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		parts[i] = sequencePart{f, offset}
+		if durations != nil {
+			offset += durations[i]
+		}
+	}
+	r := &Reader{r: &source{r: bytes.NewReader(nil)}, seq: parts}
+	return r, closeAll, nil
+}
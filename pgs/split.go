@@ -0,0 +1,101 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+)
+
+// SplitHorizontal partitions the display set into display sets for the
+// left and right halves of the frame, each half as wide, such as for
+// side-by-side 3D. Windows and composition objects are assigned to the
+// halves they fall in, with positions relative to the half, and those
+// straddling the middle are cut in two. Objects must be defined in the
+// display set itself.
+func (ds *DisplaySet) SplitHorizontal() (left, right *DisplaySet, err error) {
+	mid := int(ds.Width) / 2
+	halves := [2]image.Rectangle{
+		image.Rect(0, 0, mid, int(ds.Height)),
+		image.Rect(mid, 0, int(ds.Width), int(ds.Height)),
+	}
+	var out [2]*DisplaySet
+	for i := range out {
+		h := *ds
+		h.Width = uint16(halves[i].Dx())
+		h.Windows, h.Objects, h.Object = nil, nil, nil
+		// Raw segments no longer match the content
+		h.Headers, h.Payloads = nil, nil
+		out[i] = &h
+	}
+
+	for _, win := range ds.Windows {
+		for i, half := range halves {
+			r := win.Rect().Intersect(half).Sub(half.Min)
+			if !r.Empty() {
+				out[i].Windows = append(out[i].Windows, Window{win.ID, uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+			}
+		}
+	}
+
+	// Find the area of the object needed by each half
+	var regions [2]image.Rectangle
+	for i := range ds.Objects {
+		co := &ds.Objects[i]
+		obj, err := ds.object(co.ObjectID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("composition object %d/%d: %w", i+1, len(ds.Objects), err)
+		}
+		pt := image.Point{int(co.X), int(co.Y)}
+		for j, half := range halves {
+			r := co.rect(&obj.Image).Intersect(half)
+			if !r.Empty() {
+				regions[j] = regions[j].Union(r.Sub(pt))
+			}
+		}
+	}
+	if ds.Object != nil && len(ds.Objects) == 0 {
+		return nil, nil, fmt.Errorf("object %d not placed in display set", ds.Object.ID)
+	}
+	if len(ds.Objects) == 0 {
+		return out[0], out[1], nil
+	}
+
+	obj := ds.Object
+	pix := make([]uint8, obj.DecodedSize())
+	if err := obj.DecodeInto(pix, nil); err != nil {
+		return nil, nil, fmt.Errorf("object %d: %w", obj.ID, err)
+	}
+	pimg := &image.Paletted{Pix: pix, Stride: int(obj.Width), Rect: image.Rect(0, 0, int(obj.Width), int(obj.Height))}
+	for j, half := range halves {
+		region := regions[j]
+		if region.Empty() {
+			continue
+		}
+		img, err := EncodeRLE(pimg.SubImage(region).(*image.Paletted))
+		if err != nil {
+			return nil, nil, fmt.Errorf("object %d: %w", obj.ID, err)
+		}
+		part := *obj
+		part.Image = *img
+		part.DataLen = len(img.Data)
+		part.spill = nil
+		out[j].Object = &part
+
+		for _, co := range ds.Objects {
+			visible := co.rect(&obj.Image).Intersect(half)
+			if visible.Empty() {
+				continue
+			}
+			pos := image.Point{int(co.X), int(co.Y)}.Add(region.Min).Sub(half.Min)
+			if pos.X < 0 || pos.Y < 0 {
+				return nil, nil, fmt.Errorf("object %d: placed at conflicting positions across the middle", obj.ID)
+			}
+			co.X, co.Y = uint16(pos.X), uint16(pos.Y)
+			visible = visible.Sub(half.Min)
+			if co.Crop != nil || visible != (image.Rectangle{pos, pos.Add(region.Size())}) {
+				co.Crop = &CompositionObjectCrop{uint16(visible.Min.X), uint16(visible.Min.Y), uint16(visible.Dx()), uint16(visible.Dy())}
+			}
+			out[j].Objects = append(out[j].Objects, co)
+		}
+	}
+	return out[0], out[1], nil
+}
@@ -0,0 +1,51 @@
+package pgs
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+)
+
+// Sprite is a distinct object bitmap in a stream.
+type Sprite struct {
+	Image *image.RGBA
+	Times []time.Duration // Presentation times of the display sets defining it
+}
+
+// ExtractSprites reads the stream and returns each distinct object
+// bitmap once, in order of first appearance. Bitmaps are compared by
+// their pixels after applying the palette, so an object redefined with
+// the same appearance is one sprite. Only objects defined together with
+// a palette are extracted.
+func ExtractSprites(r *Reader) ([]Sprite, error) {
+	var sprites []Sprite
+	seen := make(map[string]int)
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return sprites, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ds.Object == nil || ds.Palette == nil {
+			continue
+		}
+		p, err := ds.Object.Convert(ds.Palette)
+		if err != nil {
+			return nil, fmt.Errorf("display set %d: object %d: %w", i, ds.Object.ID, err)
+		}
+		img := image.NewRGBA(p.Rect)
+		draw.Draw(img, img.Rect, p, image.Point{}, draw.Src)
+		key := fmt.Sprintf("%dx%d:%s", img.Rect.Dx(), img.Rect.Dy(), img.Pix)
+		j, ok := seen[key]
+		if !ok {
+			j = len(sprites)
+			seen[key] = j
+			sprites = append(sprites, Sprite{Image: img})
+		}
+		sprites[j].Times = append(sprites[j].Times, ds.PresentationTime)
+	}
+}
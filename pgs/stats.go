@@ -0,0 +1,131 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ReferencedPaletteID returns the ID of the palette used by the
+// composition.
+func (ds *DisplaySet) ReferencedPaletteID() uint8 {
+	return ds.PaletteID
+}
+
+// PaletteUsage reads the stream and counts how many display sets use each
+// palette ID, counting those that show objects or update the palette.
+func PaletteUsage(r *Reader) (map[uint8]int, error) {
+	usage := make(map[uint8]int)
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return usage, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ds.IsClear() || ds.PaletteUpdate {
+			usage[ds.ReferencedPaletteID()]++
+		}
+	}
+}
+
+// DecodeComplexity returns the number of run-length encoded runs in the
+// object defined by the display set, as an estimate of the cost of
+// decoding it. Runs are a better proxy for decoder work than pixels,
+// since long runs of one color are filled cheaply. A display set that
+// defines no object has zero complexity.
+func (ds *DisplaySet) DecodeComplexity() (int, error) {
+	if ds.Object == nil {
+		return 0, nil
+	}
+	runs := 0
+	err := ds.Object.decodeRuns(func(x, y, n int, c uint8) {
+		runs++
+	})
+	if err != nil {
+		return 0, fmt.Errorf("object %d: %w", ds.Object.ID, err)
+	}
+	return runs, nil
+}
+
+// UnusedPaletteEntries returns the IDs of the entries of the palette
+// that no pixel of the object uses, in palette order. The object and
+// palette must be defined in the display set itself.
+func (ds *DisplaySet) UnusedPaletteEntries() ([]uint8, error) {
+	if ds.Palette == nil {
+		return nil, errors.New("palette not defined in display set")
+	}
+	if ds.Object == nil {
+		return nil, errors.New("object not defined in display set")
+	}
+	used, err := ds.Object.UsedIndices()
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", ds.Object.ID, err)
+	}
+	var unused []uint8
+	for _, e := range ds.Palette.Entries {
+		if used[e.ID] == 0 {
+			unused = append(unused, e.ID)
+		}
+	}
+	return unused, nil
+}
+
+// PaletteEfficiency returns the fraction of the entries of the palette
+// used by the object, as with UnusedPaletteEntries. A low efficiency
+// suggests that the palette could be shrunk. An empty palette has an
+// efficiency of 1.
+func (ds *DisplaySet) PaletteEfficiency() (float64, error) {
+	unused, err := ds.UnusedPaletteEntries()
+	if err != nil {
+		return 0, err
+	}
+	n := len(ds.Palette.Entries)
+	if n == 0 {
+		return 1, nil
+	}
+	return float64(n-len(unused)) / float64(n), nil
+}
+
+// MaxConcurrentObjects reads the stream and returns the most composition
+// objects shown at once, for checking against decoder limits. Each
+// composition describes everything on screen, across all of its windows,
+// so objects shown together are those of one display set.
+func MaxConcurrentObjects(r *Reader) (int, error) {
+	peak := 0
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return peak, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(ds.Objects) > peak {
+			peak = len(ds.Objects)
+		}
+	}
+}
+
+// ObjectDimensions reads the stream and counts the objects defined with
+// each distinct width and height. Many distinct sizes suggest text
+// encoded per line, while a few large sizes suggest full-screen signs.
+// Object data is skipped, and the option of r is restored on return.
+func ObjectDimensions(r *Reader) (map[[2]int]int, error) {
+	defer r.SkipObjectData(r.skipData)
+	r.SkipObjectData(true)
+	dims := make(map[[2]int]int)
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return dims, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range ds.DefinedObjects() {
+			dims[[2]int{int(obj.Width), int(obj.Height)}]++
+		}
+	}
+}
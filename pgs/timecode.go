@@ -0,0 +1,69 @@
+package pgs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrUnknownFrameRate is returned when the frame rate of a composition is
+// not recognized and no frame rate is assumed.
+var ErrUnknownFrameRate = errors.New("frame rate unknown")
+
+// FPS returns the frames per second of the composition. When the frame
+// rate code is zero or not recognized, it returns assumed, if positive,
+// or ErrUnknownFrameRate, rather than silently guessing.
+func (pc *PresentationComposition) FPS(assumed float64) (float64, error) {
+	if fps := pc.FrameRate.FPS(); fps != 0 {
+		return fps, nil
+	}
+	if assumed > 0 {
+		return assumed, nil
+	}
+	return 0, fmt.Errorf("%w: code 0x%x", ErrUnknownFrameRate, uint8(pc.FrameRate))
+}
+
+// Timecode formats d as an SMPTE HH:MM:SS:FF timecode at fps frames per
+// second. Hours, minutes, and seconds are from the clock time and frames
+// count the remaining fraction of a second, rounded to the nearest frame.
+func Timecode(d time.Duration, fps float64) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int64(d / time.Second)
+	f := int64(math.Round(float64(d%time.Second) / float64(time.Second) * fps))
+	if f >= int64(math.Ceil(fps)) {
+		s++
+		f = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", s/3600, s/60%60, s%60, f)
+}
+
+// ParseTimecode parses an SMPTE HH:MM:SS:FF timecode at fps frames per
+// second, as formatted by Timecode, into the start time of its frame.
+func ParseTimecode(tc string, fps float64) (time.Duration, error) {
+	if !(fps > 0) {
+		return 0, fmt.Errorf("invalid frame rate: %g", fps)
+	}
+	var h, m, s, f int64
+	if n, err := fmt.Sscanf(tc, "%d:%d:%d:%d", &h, &m, &s, &f); err != nil || n != 4 {
+		return 0, fmt.Errorf("timecode %q not HH:MM:SS:FF", tc)
+	}
+	if h < 0 || m < 0 || m >= 60 || s < 0 || s >= 60 || f < 0 || float64(f) >= math.Ceil(fps) {
+		return 0, fmt.Errorf("timecode %q out of range", tc)
+	}
+	d := time.Duration(h*3600+m*60+s) * time.Second
+	return d + time.Duration(math.Round(float64(f)/fps*float64(time.Second))), nil
+}
+
+// FrameRateCode returns the frame rate code of the video at fps frames
+// per second, or false if fps is not a BluRay frame rate.
+func FrameRateCode(fps float64) (FrameRate, bool) {
+	for _, f := range []FrameRate{FrameRate23976, FrameRate24, FrameRate25, FrameRate2997, FrameRate50, FrameRate5994} {
+		if math.Abs(f.FPS()-fps) < 0.01 {
+			return f, true
+		}
+	}
+	return 0, false
+}
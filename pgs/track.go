@@ -0,0 +1,21 @@
+package pgs
+
+// TrackInfo is the metadata of a subtitle track from the headers of a
+// container. Raw PGS streams carry none, so it is only populated when
+// extracting a stream from a container.
+type TrackInfo struct {
+	Language string // ISO 639-2 language code, such as "eng"
+	Name     string
+	Default  bool
+	Forced   bool
+}
+
+// Filename returns base with the language of the track and ext appended,
+// such as "out.eng.srt", for labeling converted output. The language is
+// omitted when unknown.
+func (t *TrackInfo) Filename(base, ext string) string {
+	if t.Language != "" {
+		base += "." + t.Language
+	}
+	return base + ext
+}
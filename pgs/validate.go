@@ -2,12 +2,22 @@ package pgs
 
 import "fmt"
 
-func (h *header) validate() error {
+func (h *Header) validate() error {
+	return h.check(false)
+}
+
+// check validates the header, accepting segments of unrecognized types
+// when allowUnknown is set and of registered vendor-specific types.
+func (h *Header) check(allowUnknown bool) error {
 	if h.MagicNumber != 0x5047 {
 		return fmt.Errorf(`magic number not "PG" 0x5047: %x`, h.MagicNumber)
 	}
 	switch h.SegmentType {
-	case PCSType, WDSType, ODSType:
+	case PCSType:
+		if h.SegmentSize < 11 {
+			return fmt.Errorf("segment size too small: %d bytes", h.SegmentSize)
+		}
+	case WDSType, ODSType:
 	case PDSType:
 		if h.SegmentSize%5 != 2 {
 			return fmt.Errorf("invalid segment size: %d bytes", h.SegmentSize)
@@ -17,16 +27,23 @@ func (h *header) validate() error {
 			return fmt.Errorf("nonzero segment size: %d bytes", h.SegmentSize)
 		}
 	default:
-		return fmt.Errorf("unrecognized segment type: 0x%x", h.SegmentType)
+		if _, ok := segmentDecoder(h.SegmentType); !ok && !allowUnknown {
+			return fmt.Errorf("unrecognized segment type: %s", h.SegmentType)
+		}
 	}
-	if h.DecodingTime > h.PresentationTime {
+	// Decoding time may be before a wraparound of the clock
+	if h.DecodingTime > h.PresentationTime && h.DecodingTime-h.PresentationTime < 1<<31 {
 		return fmt.Errorf("decoding time %s (0x%x) after presentation time %s (0x%x)",
 			h.DecodingTime.Duration(), h.DecodingTime, h.PresentationTime.Duration(), h.PresentationTime)
 	}
 	return nil
 }
 
-func (pcs *pcs) validate() error {
+func (pcs *pcs) validate(segmentSize uint16) error {
+	// Each composition object takes at least 8 bytes
+	if int(pcs.ObjectCount)*8 > int(segmentSize)-11 {
+		return fmt.Errorf("segment size %d too small for %d composition objects", segmentSize, pcs.ObjectCount)
+	}
 	switch pcs.CompositionState {
 	case Normal, AcquisitionPoint, EpochStart:
 	default:
@@ -39,7 +56,7 @@ func (pcs *pcs) validate() error {
 }
 
 func (obj *pcsObject) validate() error {
-	if obj.ObjectCropped&^croppedForce != 0 {
+	if obj.ObjectCropped&^(croppedOn|forcedOn) != 0 {
 		return fmt.Errorf("unrecognized object crop flag: 0x%x", obj.ObjectCropped)
 	}
 	return nil
@@ -54,20 +71,24 @@ func (wds *wds) validate(segmentSize uint16) error {
 }
 
 func (p *Palette) validate(segmentSize uint16) error {
-	ids := make(map[uint8]struct{}, len(p.Entries))
+	var ids [256]bool
 	for _, e := range p.Entries {
-		if _, ok := ids[e.ID]; ok {
+		if ids[e.ID] {
 			return fmt.Errorf("id reused: %d", e.ID)
 		}
-		ids[e.ID] = struct{}{}
+		ids[e.ID] = true
 	}
 	return nil
 }
 
 func (ods *ods) validate(segmentSize uint16) error {
-	// Object data length may overflow segment size
+	// Object data length overflows the segment size when the data
+	// continues in fragments
+	if segmentSize < 11 {
+		return fmt.Errorf("segment size %d excludes object header", segmentSize)
+	}
 	l := ods.ObjectDataLength.Int()
-	if l < 0xffff-11 && l != int(segmentSize)-7 {
+	if n := int(segmentSize) - 7; l < n || l > n && ods.SequenceFlag&lastInSequence != 0 {
 		return fmt.Errorf("segment size %d not consistent with object data length %d", segmentSize, l)
 	}
 	if l < 4 {
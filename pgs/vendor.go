@@ -0,0 +1,81 @@
+package pgs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// VendorSegment is a segment of a type other than the five standard
+// types, as written by some authoring tools.
+type VendorSegment struct {
+	Type  SegmentType
+	Data  []byte      // Raw payload
+	Value interface{} // Decoded payload, when the type is registered
+}
+
+var (
+	segmentDecodersMu sync.RWMutex
+	segmentDecoders   = make(map[SegmentType]func(io.Reader, uint16) (interface{}, error))
+)
+
+// RegisterSegmentType registers a decoder for a vendor-specific segment
+// type, so that the Reader accepts segments of the type and keeps them
+// in DisplaySet.Vendor. The decoder is called with the payload and its
+// size. Registering a standard type panics.
+func RegisterSegmentType(t SegmentType, decoder func(r io.Reader, size uint16) (interface{}, error)) {
+	switch t {
+	case PCSType, WDSType, PDSType, ODSType, ENDType:
+		panic(fmt.Sprintf("pgs: register of standard segment type %s", t))
+	}
+	segmentDecodersMu.Lock()
+	defer segmentDecodersMu.Unlock()
+	segmentDecoders[t] = decoder
+}
+
+func segmentDecoder(t SegmentType) (func(io.Reader, uint16) (interface{}, error), bool) {
+	segmentDecodersMu.RLock()
+	defer segmentDecodersMu.RUnlock()
+	dec, ok := segmentDecoders[t]
+	return dec, ok
+}
+
+// readVendor reads a segment of a vendor-specific type, decoding it when
+// the type is registered.
+func (r *Reader) readVendor(h *Header) (*VendorSegment, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r.r, int64(h.SegmentSize)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	seg := &VendorSegment{Type: h.SegmentType, Data: buf.Bytes()}
+	if dec, ok := segmentDecoder(h.SegmentType); ok {
+		v, err := dec(bytes.NewReader(seg.Data), h.SegmentSize)
+		if err != nil {
+			return nil, err
+		}
+		seg.Value = v
+	}
+	return seg, nil
+}
+
+func (w *Writer) writeVendor(h Header, seg *VendorSegment) error {
+	switch seg.Type {
+	case PCSType, WDSType, PDSType, ODSType, ENDType:
+		return fmt.Errorf("standard segment type %s", seg.Type)
+	}
+	if len(seg.Data) > 0xffff {
+		return fmt.Errorf("payload length overflow: %d", len(seg.Data))
+	}
+	h.SegmentType = seg.Type
+	h.SegmentSize = uint16(len(seg.Data))
+	if err := binary.Write(w.w, binary.BigEndian, &h); err != nil {
+		return err
+	}
+	_, err := w.w.Write(seg.Data)
+	return err
+}
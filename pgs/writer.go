@@ -2,16 +2,97 @@ package pgs
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 )
 
 type Writer struct {
-	w io.Writer
+	w           *countingWriter
+	passthrough bool
+	lead        bool
+	leadFixed   time.Duration
+	pixelRate   int
 }
 
+// DefaultPixelRate is a suggested pixel rate for DecodingLead, which
+// gives a large full-frame object a lead of around an eighth of a second.
+const DefaultPixelRate = 16000000
+
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{w}
+	return &Writer{w: &countingWriter{w: w}}
+}
+
+// DryRun controls whether display sets are encoded and checked as usual,
+// but not written, so that a transform can be run without output, such
+// as to measure it with Written.
+func (w *Writer) DryRun(dry bool) {
+	w.w.dry = dry
+}
+
+// Written returns the number of bytes written, or that would have been
+// written when in a dry run.
+func (w *Writer) Written() int64 {
+	return w.w.n
+}
+
+// countingWriter counts the bytes written to w, discarding them in a dry
+// run.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	dry bool
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.dry {
+		cw.n += int64(len(b))
+		return len(b), nil
+	}
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
+
+// PassthroughPayloads controls whether display sets with payloads kept
+// by the Reader are written from those payloads verbatim, with only the
+// timestamps of the headers replaced by those of the display set. This
+// guarantees that timing-only edits leave the content byte-for-byte
+// unchanged, but any other edits to such display sets are ignored.
+func (w *Writer) PassthroughPayloads(pass bool) {
+	w.passthrough = pass
+}
+
+// DecodingLead sets the writer to derive decoding times from
+// presentation times, ignoring the decoding times of the display sets,
+// so that decoders have time to decode objects before showing them.
+// Decoding starts fixed before presentation, plus the time to decode the
+// object at pixelRate pixels per second, clamped to the start of the
+// clock. A pixelRate of 0 uses the fixed lead alone. Strict decoders
+// reject decoding times equal to presentation times for large objects;
+// a fixed lead of 0 with DefaultPixelRate is a sensible choice.
+func (w *Writer) DecodingLead(fixed time.Duration, pixelRate int) {
+	w.lead, w.leadFixed, w.pixelRate = true, fixed, pixelRate
+}
+
+// decodingTime returns the decoding time to write for the display set.
+func (w *Writer) decodingTime(ds *DisplaySet) time.Duration {
+	if !w.lead {
+		return ds.DecodingTime
+	}
+	lead := w.leadFixed
+	if w.pixelRate > 0 {
+		var pixels int64
+		for _, obj := range ds.DefinedObjects() {
+			pixels += int64(obj.Width) * int64(obj.Height)
+		}
+		lead += time.Duration(pixels * int64(time.Second) / int64(w.pixelRate))
+	}
+	if lead > ds.PresentationTime {
+		return 0
+	}
+	return ds.PresentationTime - lead
 }
 
 func (w *Writer) WriteAll(stream []DisplaySet) error {
@@ -24,10 +105,13 @@ func (w *Writer) WriteAll(stream []DisplaySet) error {
 }
 
 func (w *Writer) Write(ds *DisplaySet) error {
-	h := header{
+	h := Header{
 		MagicNumber:      0x5047,
 		PresentationTime: fromDuration(ds.PresentationTime),
-		DecodingTime:     fromDuration(ds.DecodingTime),
+		DecodingTime:     fromDuration(w.decodingTime(ds)),
+	}
+	if w.passthrough && len(ds.Payloads) != 0 {
+		return w.writePayloads(h, ds)
 	}
 	if err := w.writePresentationComposition(h, &ds.PresentationComposition); err != nil {
 		return fmt.Errorf("presentation composition segment: %w", err)
@@ -37,28 +121,53 @@ func (w *Writer) Write(ds *DisplaySet) error {
 			return fmt.Errorf("window definition segment: %w", err)
 		}
 	}
-	if ds.Palette != nil {
-		if err := w.writePalette(h, ds.Palette); err != nil {
+	for _, p := range ds.DefinedPalettes() {
+		if err := w.writePalette(h, p); err != nil {
 			return fmt.Errorf("palette definition segment: %w", err)
 		}
 	}
-	if ds.Object != nil {
-		if err := w.writeObject(h, ds.Object); err != nil {
-			return fmt.Errorf("palette definition segment: %w", err)
+	for _, obj := range ds.DefinedObjects() {
+		if err := w.writeObject(h, obj); err != nil {
+			return fmt.Errorf("object definition segment: %w", err)
+		}
+	}
+	for i := range ds.Vendor {
+		if err := w.writeVendor(h, &ds.Vendor[i]); err != nil {
+			return fmt.Errorf("vendor segment %s: %w", ds.Vendor[i].Type, err)
 		}
 	}
 	h.SegmentType = ENDType
 	return w.writeHeader(&h)
 }
 
-func (w *Writer) writeHeader(h *header) error {
+func (w *Writer) writePayloads(h Header, ds *DisplaySet) error {
+	if len(ds.Payloads) != len(ds.Headers) {
+		return fmt.Errorf("%d payloads kept for %d headers", len(ds.Payloads), len(ds.Headers))
+	}
+	for i, p := range ds.Payloads {
+		h.SegmentType = ds.Headers[i].SegmentType
+		if len(p) > 0xffff {
+			return fmt.Errorf("%s segment: payload length overflow: %d", h.SegmentType, len(p))
+		}
+		h.SegmentSize = uint16(len(p))
+		if err := w.writeHeader(&h); err != nil {
+			return fmt.Errorf("%s segment: %w", h.SegmentType, err)
+		}
+		if _, err := w.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writeHeader(h *Header) error {
 	if err := h.validate(); err != nil {
 		return err
 	}
 	return binary.Write(w.w, binary.BigEndian, h)
 }
 
-func (w *Writer) writePresentationComposition(h header, pc *PresentationComposition) error {
+func (w *Writer) writePresentationComposition(h Header, pc *PresentationComposition) error {
 	if len(pc.Objects) > 0xff {
 		return fmt.Errorf("object count overflow: %d", len(pc.Objects))
 	}
@@ -86,7 +195,7 @@ func (w *Writer) writePresentationComposition(h header, pc *PresentationComposit
 		PaletteID:         pc.PaletteID,
 		ObjectCount:       uint8(len(pc.Objects)),
 	}
-	if err := pcs.validate(); err != nil {
+	if err := pcs.validate(size); err != nil {
 		return err
 	}
 
@@ -99,7 +208,10 @@ func (w *Writer) writePresentationComposition(h header, pc *PresentationComposit
 	for i, obj := range pc.Objects {
 		var cropped objectCroppedFlag
 		if obj.Crop != nil {
-			cropped |= croppedForce
+			cropped |= croppedOn
+		}
+		if obj.Forced {
+			cropped |= forcedOn
 		}
 		o := pcsObject{
 			ObjectID:      obj.ObjectID,
@@ -115,7 +227,7 @@ func (w *Writer) writePresentationComposition(h header, pc *PresentationComposit
 			return err
 		}
 		if obj.Crop != nil {
-			if err := binary.Write(w.w, binary.BigEndian, &obj.Crop); err != nil {
+			if err := binary.Write(w.w, binary.BigEndian, obj.Crop); err != nil {
 				return err
 			}
 		}
@@ -123,7 +235,7 @@ func (w *Writer) writePresentationComposition(h header, pc *PresentationComposit
 	return nil
 }
 
-func (w *Writer) writeWindows(h header, ws []Window) error {
+func (w *Writer) writeWindows(h Header, ws []Window) error {
 	if len(ws) > 0xff {
 		return fmt.Errorf("window count overflow: %d", len(ws))
 	}
@@ -148,7 +260,7 @@ func (w *Writer) writeWindows(h header, ws []Window) error {
 	return nil
 }
 
-func (w *Writer) writePalette(h header, p *Palette) error {
+func (w *Writer) writePalette(h Header, p *Palette) error {
 	h.SegmentType = PDSType
 	h.SegmentSize = uint16(len(p.Entries)*5 + 2)
 	pds := &pds{p.ID, p.Version}
@@ -165,21 +277,37 @@ func (w *Writer) writePalette(h header, p *Palette) error {
 	return binary.Write(w.w, binary.BigEndian, p.Entries)
 }
 
-func (w *Writer) writeObject(h header, obj *Object) error {
-	if len(obj.Data) > 0xffffff-4 {
-		return fmt.Errorf("object data length overflow: %d", len(obj.Data))
+// Maximum lengths of object data in the first object definition segment,
+// after its header, and in each following fragment
+const (
+	maxFirstFragment = 0xffff - 11
+	maxFragment      = 0xffff - 4
+)
+
+func (w *Writer) writeObject(h Header, obj *Object) error {
+	dataLen := len(obj.Data)
+	if obj.Data == nil && obj.spill != nil {
+		dataLen = int(obj.spill.Size())
+	} else if obj.Data == nil && obj.DataLen != 0 {
+		return errors.New("object data was skipped when read")
+	}
+	if dataLen > 0xffffff-4 {
+		return fmt.Errorf("object data length overflow: %d", dataLen)
 	}
+	// Data that does not fit in one segment continues in fragments
+	sizes := obj.fragmentSizes(dataLen)
+	first := sizes[0]
 	h.SegmentType = ODSType
-	h.SegmentSize = uint16(len(obj.Data) + 11)
+	h.SegmentSize = uint16(first + 11)
 
 	var seq sequenceFlag
 	if obj.First {
 		seq |= firstInSequence
 	}
-	if obj.Last {
+	if obj.Last && len(sizes) == 1 {
 		seq |= lastInSequence
 	}
-	l, err := uint24FromInt(len(obj.Data) + 4)
+	l, err := uint24FromInt(dataLen + 4)
 	if err != nil {
 		return err
 	}
@@ -201,5 +329,51 @@ func (w *Writer) writeObject(h header, obj *Object) error {
 	if err := binary.Write(w.w, binary.BigEndian, ods); err != nil {
 		return err
 	}
-	return binary.Write(w.w, binary.BigEndian, obj.Data)
+	data := obj.DataReader()
+	if _, err := io.CopyN(w.w, data, int64(first)); err != nil {
+		return err
+	}
+	for i, n := range sizes[1:] {
+		var seq sequenceFlag
+		if obj.Last && i == len(sizes)-2 {
+			seq |= lastInSequence
+		}
+		h.SegmentSize = uint16(n + 4)
+		if err := w.writeHeader(&h); err != nil {
+			return err
+		}
+		frag := &odsFragment{obj.ID, obj.Version, seq}
+		if err := binary.Write(w.w, binary.BigEndian, frag); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w.w, data, int64(n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fragmentSizes returns the data length of each segment to write for the
+// object with dataLen bytes of data: the lengths read, when they add up
+// to dataLen and fit their segments, so that unchanged objects are
+// written as read, or else the data split at the maximum segment sizes.
+func (obj *Object) fragmentSizes(dataLen int) []int {
+	if sizes := obj.fragments; len(sizes) != 0 && sizes[0] <= maxFirstFragment {
+		sum := sizes[0]
+		for _, n := range sizes[1:] {
+			if n > maxFragment {
+				sum = -1
+				break
+			}
+			sum += n
+		}
+		if sum == dataLen {
+			return sizes
+		}
+	}
+	sizes := []int{min(dataLen, maxFirstFragment)}
+	for rest := dataLen - sizes[0]; rest > 0; rest -= maxFragment {
+		sizes = append(sizes, min(rest, maxFragment))
+	}
+	return sizes
 }
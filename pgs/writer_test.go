@@ -0,0 +1,205 @@
+package pgs
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 80, 3), color.Palette{color.Transparent, color.White, color.Black})
+	for x := 0; x < 80; x++ {
+		img.SetColorIndex(x, 1, uint8(1+x/70))
+	}
+	show, err := NewDisplaySet(img, 10, 20, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	show.Width, show.Height = 1920, 1080
+	show.Windows = append(show.Windows, Window{ID: 1, X: 500, Y: 600, Width: 10, Height: 10})
+	show.Objects[0].Crop = &CompositionObjectCrop{X: 12, Y: 20, Width: 60, Height: 2}
+	show.Objects[0].Forced = true
+	update := NewClearDisplaySet(1500*time.Millisecond, 0)
+	update.Width, update.Height = 1920, 1080
+	update.Objects = []CompositionObject{show.Objects[0]}
+	update.Objects[0].Crop = nil // Forced without cropping
+	update.PaletteUpdate = true
+	update.Palette = &Palette{Version: 1, Entries: append([]PaletteEntry(nil), show.Palette.Entries...)}
+	update.Palette.Entries[1].A = 0x80
+	clear := NewClearDisplaySet(2*time.Second, 0)
+	clear.Width, clear.Height = 1920, 1080
+	clear.Objects = []CompositionObject{} // As read
+	stream := []DisplaySet{*show, *update, *clear}
+	for i := range stream {
+		stream[i].CompositionNumber = uint16(i)
+	}
+
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewReader(bytes.NewReader(b.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(stream) {
+		t.Fatalf("read %d display sets, want %d", len(got), len(stream))
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i].PresentationComposition, stream[i].PresentationComposition) {
+			t.Errorf("display set %d: composition %+v, want %+v", i, got[i].PresentationComposition, stream[i].PresentationComposition)
+		}
+	}
+	var b2 bytes.Buffer
+	if err := NewWriter(&b2).WriteAll(got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b2.Bytes(), b.Bytes()) {
+		t.Errorf("rewritten stream differs:\n% x\nwant:\n% x", b2.Bytes(), b.Bytes())
+	}
+}
+
+func TestWriterObjectFragments(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 400, 400), color.Palette{color.Transparent, color.White})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 2)
+	}
+	ds, err := NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).Write(ds); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []uint16
+	err = ScanHeaders(bytes.NewReader(b.Bytes()), func(typ SegmentType, pts, dts time.Duration, size uint16, offset int64) error {
+		if typ == ODSType {
+			sizes = append(sizes, size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint16{0xffff}
+	for rest := len(ds.Object.Data) - maxFirstFragment; rest > 0; rest -= maxFragment {
+		want = append(want, uint16(min(rest, maxFragment)+4))
+	}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("object definition segment sizes: got %v, want %v", sizes, want)
+	}
+
+	got, err := NewReader(bytes.NewReader(b.Bytes())).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Object.Data, ds.Object.Data) {
+		t.Error("object data not joined")
+	}
+	var b2 bytes.Buffer
+	if err := NewWriter(&b2).Write(got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b2.Bytes(), b.Bytes()) {
+		t.Error("rewritten stream differs")
+	}
+}
+
+func TestWriterKeepsFragmentSizes(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{color.Transparent, color.White})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 3 % 2)
+	}
+	ds, err := NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).Write(ds); err != nil {
+		t.Fatal(err)
+	}
+	// Fragments well below the maximum segment size, as some encoders
+	// write them
+	sup := splitObject(t, b.Bytes(), 100)
+	got, err := NewReader(bytes.NewReader(sup)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b2 bytes.Buffer
+	if err := NewWriter(&b2).Write(got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b2.Bytes(), sup) {
+		t.Errorf("rewritten stream differs:\n% x\nwant:\n% x", b2.Bytes(), sup)
+	}
+	if n := got.SegmentCount(); n != 6 {
+		t.Errorf("counted %d segments, want 6", n)
+	}
+
+	// Changed data is split again
+	got.Object.Data = append([]byte(nil), ds.Object.Data...)
+	got.Object.Data = append(got.Object.Data, 0, 0)
+	b2.Reset()
+	if err := NewWriter(&b2).Write(got); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []uint16
+	err = ScanHeaders(bytes.NewReader(b2.Bytes()), func(typ SegmentType, pts, dts time.Duration, size uint16, offset int64) error {
+		if typ == ODSType {
+			sizes = append(sizes, size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{uint16(len(got.Object.Data) + 11)}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("object definition segment sizes: got %v, want %v", sizes, want)
+	}
+}
+
+func TestWriterRoundTripSpilledFragments(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{color.Transparent, color.White})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 3 % 2)
+	}
+	ds, err := NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := NewWriter(&b).WriteAll([]DisplaySet{*ds, *NewClearDisplaySet(2*time.Second, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	// Fragments well below the maximum segment size, followed by another
+	// display set
+	sup := splitObject(t, b.Bytes(), 1000)
+
+	f, err := os.CreateTemp(t.TempDir(), "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, spill := range []bool{false, true} {
+		r := NewReader(bytes.NewReader(sup))
+		if spill {
+			r.SpillObjectData(f)
+		}
+		stream, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b2 bytes.Buffer
+		if err := NewWriter(&b2).WriteAll(stream); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b2.Bytes(), sup) {
+			t.Errorf("spill %t: rewritten stream differs", spill)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package trans
+
+import (
+	"bytes"
+	"image"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// CollapseIdentical copies the stream, merging consecutive shown display
+// sets with identical rendered pixels into one longer event by dropping
+// the redundant clear and redefinition between them. Display sets that
+// cannot be rendered on their own never compare as identical.
+func CollapseIdentical(r *pgs.Reader, w *pgs.Writer) error {
+	var shown *image.RGBA     // Image on screen before the pending clear
+	var clear *pgs.DisplaySet // Clear held back until the next show
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ds.IsClear() {
+			if clear != nil {
+				if err := w.Write(clear); err != nil {
+					return err
+				}
+				shown = nil
+			}
+			if shown == nil {
+				if err := w.Write(ds); err != nil {
+					return err
+				}
+				continue
+			}
+			clear = ds
+			continue
+		}
+		img, err := ds.Render()
+		if err != nil {
+			img = nil
+		}
+		if img != nil && shown != nil && img.Rect == shown.Rect && bytes.Equal(img.Pix, shown.Pix) {
+			clear = nil
+			continue
+		}
+		if clear != nil {
+			if err := w.Write(clear); err != nil {
+				return err
+			}
+			clear = nil
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+		shown = img
+	}
+	if clear != nil {
+		return w.Write(clear)
+	}
+	return nil
+}
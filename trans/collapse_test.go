@@ -0,0 +1,52 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestCollapseIdentical(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(3)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second subtitle shows the same image as the first
+	obj := *stream[0].Object
+	obj.Version = stream[2].Object.Version
+	stream[2].Object = &obj
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := CollapseIdentical(pgs.NewReader(&b), pgs.NewWriter(&out)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var times []time.Duration
+	for _, ds := range got {
+		times = append(times, ds.PresentationTime)
+	}
+	// The first clear and the repeated show are dropped
+	want := []time.Duration{stream[0].PresentationTime, stream[3].PresentationTime,
+		stream[4].PresentationTime, stream[5].PresentationTime}
+	if len(times) != len(want) {
+		t.Fatalf("got display sets at %v, want %v", times, want)
+	}
+	for i := range want {
+		if times[i] != want[i] {
+			t.Fatalf("got display sets at %v, want %v", times, want)
+		}
+	}
+	if !got[1].IsClear() || got[2].IsClear() {
+		t.Error("merged event not followed by a clear and the third subtitle")
+	}
+}
@@ -0,0 +1,44 @@
+package trans
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// DryRunReport describes what a transform would do to a stream.
+type DryRunReport struct {
+	pgs.StreamDiff
+	SizeDelta int64 // Change in bytes from the input to the encoded output
+}
+
+// DryRun runs a transform, such as a call of Transform or of any of the
+// specific transforms, on the stream read from r, and reports how the
+// output would differ from the input instead of writing it. Display sets
+// are matched as by pgs.CompareStreams. The size change is from the bytes
+// of the input as read to the output as encoded by a Writer with default
+// options, and both streams are held in memory. To only check that a
+// transform succeeds, without comparing, use a Writer in a dry run, as
+// set by pgs.Writer.DryRun.
+func DryRun(r io.Reader, transform func(r *pgs.Reader, w *pgs.Writer) error) (*DryRunReport, error) {
+	var raw bytes.Buffer
+	in, err := pgs.NewReader(io.TeeReader(r, &raw)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var outBuf bytes.Buffer
+	w := pgs.NewWriter(&outBuf)
+	if err := transform(pgs.NewReader(bytes.NewReader(raw.Bytes())), w); err != nil {
+		return nil, err
+	}
+	out, err := pgs.NewReader(&outBuf).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := pgs.CompareStreams(in, out)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunReport{diff, w.Written() - int64(raw.Len())}, nil
+}
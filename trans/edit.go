@@ -0,0 +1,243 @@
+package trans
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Trim copies the part of the stream presented from from to to, moved to
+// start at zero. Content on screen at from is shown from zero by an
+// EpochStart composition carrying its palette and object, and content
+// on screen at to is cleared, so the result can be decoded on its own.
+// Composition numbers count up from zero.
+func Trim(r *pgs.Reader, w *pgs.Writer, from, to time.Duration) error {
+	if from < 0 || to <= from {
+		return fmt.Errorf("invalid range %s to %s", from, to)
+	}
+	out := &editOutput{w: w}
+	return edit(r, []editPart{{from, to, -from, out}}, out)
+}
+
+// Cut copies the stream without the part presented from from to to,
+// moving later display sets earlier to close the gap, such as to remove
+// ads or credits. Content on screen at either end of the cut is cleared
+// and shown again, as with Trim.
+func Cut(r *pgs.Reader, w *pgs.Writer, from, to time.Duration) error {
+	if from < 0 || to <= from {
+		return fmt.Errorf("invalid range %s to %s", from, to)
+	}
+	out := &editOutput{w: w}
+	return edit(r, []editPart{{0, from, 0, out}, {to, forever, from - to, out}}, out)
+}
+
+// Split copies the part of the stream presented before t to w1 and the
+// rest, moved to start at zero, to w2, such as for video split into
+// parts, with content on screen at t cleared and shown again, as with
+// Trim.
+func Split(r *pgs.Reader, w1, w2 *pgs.Writer, t time.Duration) error {
+	if t <= 0 {
+		return fmt.Errorf("invalid split time %s", t)
+	}
+	out1, out2 := &editOutput{w: w1}, &editOutput{w: w2}
+	return edit(r, []editPart{{0, t, 0, out1}, {t, forever, -t, out2}}, out1, out2)
+}
+
+// Concat copies r1 then r2, with offset added to the times of r2, such as
+// the duration of the video of r1, to join a stream split into parts.
+// Composition numbers count up through both. It is an error for r2 to
+// start before r1 ends.
+func Concat(r1, r2 *pgs.Reader, w *pgs.Writer, offset time.Duration) error {
+	out := &editOutput{w: w}
+	if err := edit(r1, []editPart{{0, forever, 0, out}}); err != nil {
+		return err
+	}
+	return edit(r2, []editPart{{0, forever, offset, out}}, out)
+}
+
+const forever = time.Duration(math.MaxInt64)
+
+// editPart is a range of presentation times of an input stream copied to
+// an output, with shift added to its times.
+type editPart struct {
+	from, to time.Duration
+	shift    time.Duration
+	out      *editOutput
+}
+
+// edit copies the parts of r, which must be in order and not overlap, to
+// their outputs, then flushes the outputs.
+func edit(r *pgs.Reader, parts []editPart, outs ...*editOutput) error {
+	var epoch pgs.Epoch // Display sets read in the current epoch
+	p, active := 0, false
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for p < len(parts) && ds.PresentationTime >= parts[p].to {
+			pt := &parts[p]
+			if !active {
+				if err := pt.out.begin(&epoch, pt, ds.PresentationTime); err != nil {
+					return fmt.Errorf("display set %d: %w", i, err)
+				}
+			}
+			pt.out.end(pt.to + pt.shift)
+			p, active = p+1, false
+		}
+		if p < len(parts) && !active && ds.PresentationTime >= parts[p].from {
+			if err := parts[p].out.begin(&epoch, &parts[p], ds.PresentationTime); err != nil {
+				return fmt.Errorf("display set %d: %w", i, err)
+			}
+			active = true
+		}
+		if ds.CompositionState == pgs.EpochStart {
+			epoch.DisplaySets = nil
+		}
+		epoch.DisplaySets = append(epoch.DisplaySets, *ds)
+		if active {
+			if err := parts[p].out.copy(&epoch, &parts[p]); err != nil {
+				return fmt.Errorf("display set %d: %w", i, err)
+			}
+		}
+	}
+	// Content still shown at the end of the stream is shown to the end of
+	// the parts
+	for ; p < len(parts); p++ {
+		pt := &parts[p]
+		if !active {
+			if err := pt.out.begin(&epoch, pt, forever); err != nil {
+				return err
+			}
+		}
+		if pt.to != forever {
+			pt.out.end(pt.to + pt.shift)
+		}
+		active = false
+	}
+	for _, out := range outs {
+		if err := out.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editOutput writes the parts copied to a stream.
+type editOutput struct {
+	w       *pgs.Writer
+	number  uint16
+	started bool
+	prev    pgs.DisplaySet  // Last display set written
+	clear   *pgs.DisplaySet // Clear not yet written
+	carried bool            // Whether the input epoch began before the part
+	start   bool            // Whether the next display set must start an epoch
+}
+
+// begin starts copying a part whose first display set is presented at
+// next. When the input epoch began before the part, its content on
+// screen at the start of the part is shown by an EpochStart.
+func (out *editOutput) begin(epoch *pgs.Epoch, pt *editPart, next time.Duration) error {
+	out.carried = len(epoch.DisplaySets) != 0
+	out.start = out.carried
+	if !out.carried || next <= pt.from {
+		return nil
+	}
+	ds, err := epoch.At(pt.from)
+	if err != nil || ds == nil {
+		return err
+	}
+	ds.DecodingTime = pt.from
+	return out.put(ds, epoch, pt.from, pt)
+}
+
+// copy copies the last display set read in the epoch, resolving the
+// palette and object it uses when they were defined before the part.
+func (out *editOutput) copy(epoch *pgs.Epoch, pt *editPart) error {
+	i := len(epoch.DisplaySets) - 1
+	ds := &epoch.DisplaySets[i]
+	if ds.CompositionState == pgs.EpochStart {
+		out.carried, out.start = false, false
+	} else if out.carried {
+		var err error
+		if ds, err = epoch.Resolve(i); err != nil {
+			return err
+		}
+	} else {
+		d := *ds
+		ds = &d
+	}
+	return out.put(ds, epoch, ds.PresentationTime, pt)
+}
+
+// put writes a copy of a display set from the epoch presented at t in
+// the input, making it an EpochStart with the windows of the epoch when
+// one is needed.
+func (out *editOutput) put(ds *pgs.DisplaySet, epoch *pgs.Epoch, t time.Duration, pt *editPart) error {
+	if out.start {
+		ds.CompositionState = pgs.EpochStart
+		ds.PaletteUpdate = false
+		for j := len(epoch.DisplaySets) - 1; j >= 0 && len(ds.Windows) == 0; j-- {
+			ds.Windows = epoch.DisplaySets[j].Windows
+		}
+		out.start = false
+	} else if out.carried && ds.Object != nil {
+		ds.PaletteUpdate = false
+	}
+	ds.PresentationTime = t + pt.shift
+	ds.DecodingTime = max(ds.DecodingTime, pt.from) + pt.shift
+	ds.Headers, ds.Payloads = nil, nil
+	return out.write(ds)
+}
+
+// end clears content on screen at the end of a part, at t in the output.
+func (out *editOutput) end(t time.Duration) {
+	if !out.started || out.prev.IsClear() {
+		return
+	}
+	clear := pgs.NewClearDisplaySet(t, out.prev.PaletteID)
+	clear.Width, clear.Height = out.prev.Width, out.prev.Height
+	clear.FrameRate = out.prev.FrameRate
+	out.clear = clear
+}
+
+// write writes the display set with the next composition number, after
+// a pending clear, unless the display set is an EpochStart at the same
+// time, which clears the screen itself.
+func (out *editOutput) write(ds *pgs.DisplaySet) error {
+	if clear := out.clear; clear != nil {
+		out.clear = nil
+		if ds.PresentationTime != clear.PresentationTime || ds.CompositionState != pgs.EpochStart {
+			if err := out.write(clear); err != nil {
+				return err
+			}
+		}
+	}
+	if out.started && ds.PresentationTime < out.prev.PresentationTime {
+		return fmt.Errorf("presentation time %s before previous %s", ds.PresentationTime, out.prev.PresentationTime)
+	}
+	ds.CompositionNumber = out.number
+	if err := out.w.Write(ds); err != nil {
+		return err
+	}
+	out.number++
+	out.started = true
+	out.prev = *ds
+	return nil
+}
+
+// flush writes a pending clear.
+func (out *editOutput) flush() error {
+	if out.clear == nil {
+		return nil
+	}
+	clear := out.clear
+	out.clear = nil
+	return out.write(clear)
+}
@@ -0,0 +1,106 @@
+package trans
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+// editStream has subtitles shown from 1s to 3s, 4s to 6s, and 7s to 9s
+// in one epoch, with the later two showing the object of the first.
+func editStream() []byte {
+	return pgstest.BuildStream(pgstest.Subtitles(3), pgstest.ReuseObjects(), pgstest.ObjectSize(40, 10))
+}
+
+// summarize reads an edited stream and describes each display set by its
+// composition state, or as a clear, and its presentation time, checking
+// that the stream can be decoded on its own.
+func summarize(t *testing.T, sup []byte) []string {
+	t.Helper()
+	stream, err := pgs.NewReader(bytes.NewReader(sup)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum []string
+	for i := range stream {
+		ds := &stream[i]
+		if int(ds.CompositionNumber) != i {
+			t.Errorf("display set %d has composition number %d", i, ds.CompositionNumber)
+		}
+		if ds.IsClear() {
+			sum = append(sum, "clear "+ds.PresentationTime.String())
+			continue
+		}
+		if _, err := ds.Render(); err != nil {
+			t.Errorf("display set %d: %v", i, err)
+		}
+		state := map[pgs.CompositionState]string{pgs.EpochStart: "start", pgs.Normal: "show", pgs.AcquisitionPoint: "acquire"}
+		sum = append(sum, fmt.Sprintf("%s %s", state[ds.CompositionState], ds.PresentationTime))
+	}
+	return sum
+}
+
+func TestTrim(t *testing.T) {
+	var out bytes.Buffer
+	if err := Trim(pgs.NewReader(bytes.NewReader(editStream())), pgs.NewWriter(&out), 5*time.Second, 8*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The subtitle on screen at 5s starts a new epoch with the object it
+	// reuses, and the one on screen at 8s is cleared
+	want := []string{"start 0s", "clear 1s", "show 2s", "clear 3s"}
+	if got := summarize(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCut(t *testing.T) {
+	var out bytes.Buffer
+	if err := Cut(pgs.NewReader(bytes.NewReader(editStream())), pgs.NewWriter(&out), 2*time.Second, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The clear at the start of the cut is replaced by the EpochStart
+	// showing the subtitle on screen at its end
+	want := []string{"start 1s", "start 2s", "clear 3s", "show 4s", "clear 6s"}
+	if got := summarize(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var out1, out2 bytes.Buffer
+	if err := Split(pgs.NewReader(bytes.NewReader(editStream())), pgs.NewWriter(&out1), pgs.NewWriter(&out2), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want1 := []string{"start 1s", "clear 3s", "show 4s", "clear 5s"}
+	if got := summarize(t, out1.Bytes()); !reflect.DeepEqual(got, want1) {
+		t.Errorf("first part: got %v, want %v", got, want1)
+	}
+	want2 := []string{"start 0s", "clear 1s", "show 2s", "clear 4s"}
+	if got := summarize(t, out2.Bytes()); !reflect.DeepEqual(got, want2) {
+		t.Errorf("second part: got %v, want %v", got, want2)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	sup := pgstest.BuildStream(pgstest.ObjectSize(40, 10))
+	var out bytes.Buffer
+	if err := Concat(pgs.NewReader(bytes.NewReader(sup)), pgs.NewReader(bytes.NewReader(sup)), pgs.NewWriter(&out), 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start 1s", "clear 3s", "start 11s", "clear 13s"}
+	if got := summarize(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The second stream would start while the first is still shown
+	err := Concat(pgs.NewReader(bytes.NewReader(sup)), pgs.NewReader(bytes.NewReader(sup)), pgs.NewWriter(&bytes.Buffer{}), time.Second)
+	if err == nil || !strings.Contains(err.Error(), "before previous") {
+		t.Errorf("got error %v, want overlap reported", err)
+	}
+}
@@ -0,0 +1,72 @@
+package trans
+
+import (
+	"io"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// tick is one period of the 90 kHz PGS clock.
+const tick = time.Second / 90000
+
+// EnforceMinDuration copies the stream, delaying the clear of each
+// subtitle shown for less than min so that it is shown for min. The
+// clear is kept just before the next display set, so a subtitle is never
+// extended over the next one. It returns the number of clears delayed.
+func EnforceMinDuration(r *pgs.Reader, w *pgs.Writer, min time.Duration) (int, error) {
+	adjusted := 0
+	var shown bool
+	var start time.Duration     // Presentation time of the shown subtitle
+	var pending *pgs.DisplaySet // Clear held back until the next display set
+	var target time.Duration    // Presentation time wanted for the pending clear
+	delay := func(limit time.Duration) {
+		t := target
+		if t > limit {
+			t = limit
+		}
+		if d := t - pending.PresentationTime; d > 0 {
+			pending.PresentationTime += d
+			pending.DecodingTime += d
+			adjusted++
+		}
+	}
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			if pending != nil {
+				delay(target)
+				if err := w.Write(pending); err != nil {
+					return adjusted, err
+				}
+			}
+			return adjusted, nil
+		}
+		if err != nil {
+			return adjusted, err
+		}
+		if pending != nil {
+			delay(ds.PresentationTime - tick)
+			if err := w.Write(pending); err != nil {
+				return adjusted, err
+			}
+			pending = nil
+		}
+		switch {
+		case ds.IsClear():
+			if shown && ds.PresentationTime-start < min {
+				pending, target = ds, start+min
+			}
+			shown = false
+		case !shown || !ds.PaletteUpdate:
+			// Palette updates, such as fades, continue the shown subtitle
+			shown, start = true, ds.PresentationTime
+		}
+		if pending == ds {
+			continue
+		}
+		if err := w.Write(ds); err != nil {
+			return adjusted, err
+		}
+	}
+}
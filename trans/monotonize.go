@@ -0,0 +1,44 @@
+package trans
+
+import (
+	"io"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// MonotonizeTimestamps copies the stream, clamping the presentation time
+// of any display set that goes backwards to the previous presentation
+// time, so that presentation times are non-decreasing. Decoding times
+// are clamped alike to the previous decoding time, but never after the
+// presentation time. It returns the number of segments adjusted, counted
+// as in pgs.DisplaySet.SegmentCount.
+func MonotonizeTimestamps(r *pgs.Reader, w *pgs.Writer) (int, error) {
+	adjusted := 0
+	var prev, prevDecoding time.Duration
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return adjusted, nil
+		}
+		if err != nil {
+			return adjusted, err
+		}
+		clamped := false
+		if ds.PresentationTime < prev {
+			ds.PresentationTime = prev
+			clamped = true
+		}
+		if dts := min(max(ds.DecodingTime, prevDecoding), ds.PresentationTime); dts != ds.DecodingTime {
+			ds.DecodingTime = dts
+			clamped = true
+		}
+		if clamped {
+			adjusted += ds.SegmentCount()
+		}
+		prev, prevDecoding = ds.PresentationTime, ds.DecodingTime
+		if err := w.Write(ds); err != nil {
+			return adjusted, err
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestMonotonizeTimestamps(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second subtitle goes back before the first clear
+	prev := stream[1].PresentationTime
+	stream[2].PresentationTime = prev - 500*time.Millisecond
+	stream[2].DecodingTime = prev - time.Second
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	n, err := MonotonizeTimestamps(pgs.NewReader(&b), pgs.NewWriter(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := stream[2].SegmentCount(); n != want {
+		t.Errorf("adjusted %d segments, want %d", n, want)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		if i > 0 && got[i].PresentationTime < got[i-1].PresentationTime {
+			t.Errorf("display set %d: presentation time %s before %s", i, got[i].PresentationTime, got[i-1].PresentationTime)
+		}
+		if i > 0 && got[i].DecodingTime < got[i-1].DecodingTime {
+			t.Errorf("display set %d: decoding time %s before %s", i, got[i].DecodingTime, got[i-1].DecodingTime)
+		}
+		if got[i].DecodingTime > got[i].PresentationTime {
+			t.Errorf("display set %d: decoding time %s after presentation time %s", i, got[i].DecodingTime, got[i].PresentationTime)
+		}
+	}
+	if got[2].PresentationTime != prev {
+		t.Errorf("clamped to %s, want %s", got[2].PresentationTime, prev)
+	}
+}
@@ -0,0 +1,22 @@
+package trans
+
+import "github.com/andrewarchi/transup/pgs"
+
+// EnsureMinOpacity copies the stream, raising the alpha of every palette
+// entry that is neither fully transparent nor at least min up to min, as
+// with Palette.EnsureMinOpacity. It returns the number of entries raised.
+func EnsureMinOpacity(r *pgs.Reader, w *pgs.Writer, min uint8) (int, error) {
+	raised := 0
+	err := Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		for _, p := range ds.DefinedPalettes() {
+			for _, e := range p.Entries {
+				if e.A != 0 && e.A < min {
+					raised++
+				}
+			}
+			*p = *p.EnsureMinOpacity(min)
+		}
+		return ds, nil
+	})
+	return raised, err
+}
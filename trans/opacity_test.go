@@ -0,0 +1,54 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestEnsureMinOpacity(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Faint entries in the palettes of both subtitles, and a second
+	// palette in the first
+	stream[0].Palette.Entries[1].A = 0x10
+	stream[2].Palette.Entries[2].A = 0x7f
+	extra := *stream[0].Palette
+	extra.ID = 1
+	extra.Entries = append([]pgs.PaletteEntry(nil), extra.Entries...)
+	stream[0].ExtraPalettes = []pgs.Palette{extra}
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	raised, err := EnsureMinOpacity(pgs.NewReader(&b), pgs.NewWriter(&out), 0x80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raised != 3 {
+		t.Errorf("raised %d entries, want 3", raised)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		for _, p := range got[i].DefinedPalettes() {
+			for _, e := range p.Entries {
+				if e.A != 0 && e.A < 0x80 {
+					t.Errorf("display set %d: palette %d: entry %d has alpha 0x%x", i, p.ID, e.ID, e.A)
+				}
+			}
+			// Transparent entries stay transparent
+			if p.Entries[0].A != 0 {
+				t.Errorf("display set %d: palette %d: transparent entry raised", i, p.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package trans
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// OptimizeRLE copies the stream, re-encoding each object with the
+// canonical encoding of pgs.EncodeRLE when it is smaller, which is
+// lossless. It returns the total number of object data bytes saved.
+func OptimizeRLE(r *pgs.Reader, w *pgs.Writer) (int, error) {
+	saved := 0
+	err := Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		for _, def := range ds.DefinedObjects() {
+			n, err := optimizeObject(def)
+			if err != nil {
+				return nil, fmt.Errorf("object %d: %w", def.ID, err)
+			}
+			saved += n
+		}
+		return ds, nil
+	})
+	return saved, err
+}
+
+// optimizeObject re-encodes the object in place as in OptimizeRLE and
+// returns the number of bytes saved.
+func optimizeObject(obj *pgs.Object) (int, error) {
+	if err := obj.LoadData(); err != nil {
+		return 0, err
+	}
+	pix := make([]uint8, obj.DecodedSize())
+	if err := obj.DecodeInto(pix, nil); err != nil {
+		return 0, err
+	}
+	img, err := pgs.EncodeRLE(&image.Paletted{
+		Pix:    pix,
+		Stride: int(obj.Width),
+		Rect:   image.Rect(0, 0, int(obj.Width), int(obj.Height)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	saved := len(obj.Data) - len(img.Data)
+	if saved <= 0 {
+		return 0, nil
+	}
+	obj.Data = img.Data
+	obj.DataLen = len(img.Data)
+	return saved, nil
+}
@@ -0,0 +1,50 @@
+package trans
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+func TestOptimizeRLE(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 100, 2), color.Palette{color.Transparent, color.White})
+	for x := range 100 {
+		img.SetColorIndex(x, 1, 1)
+	}
+	ds, err := pgs.NewDisplaySet(img, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Width, ds.Height = 100, 10
+	// Runs split in two and shorter than they could be
+	canonical := ds.Object.Data
+	ds.Object.Data = []byte{
+		0, 50, 0, 50, 0, 0, // 50×0, 50×0
+		0, 0xc0, 60, 1, 0, 0x80 | 40, 1, 0, 0, // 60×1, 40×1
+	}
+	ds.Object.DataLen = len(ds.Object.Data)
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).Write(ds); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	saved, err := OptimizeRLE(pgs.NewReader(&b), pgs.NewWriter(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 15 - len(canonical); saved != want {
+		t.Errorf("saved %d bytes, want %d", saved, want)
+	}
+	got, err := pgs.NewReader(&out).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Object.Data, canonical) {
+		t.Errorf("re-encoded % x, want % x", got.Object.Data, canonical)
+	}
+}
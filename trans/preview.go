@@ -0,0 +1,33 @@
+package trans
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// DownscalePreview copies the stream, reducing its resolution by an
+// integer factor, for quick preview rendering. Dimensions and positions
+// are divided by factor and objects are resampled as in Rescale.
+func DownscalePreview(r *pgs.Reader, w *pgs.Writer, factor int) error {
+	if factor < 1 {
+		return fmt.Errorf("invalid downscale factor: %d", factor)
+	}
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		width, height := max(int(ds.Width)/factor, 1), max(int(ds.Height)/factor, 1)
+		if err := scaleDisplaySet(ds, width, height, pgs.Bilinear); err != nil {
+			return fmt.Errorf("display set %d: %w", i, err)
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package trans
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Recolor copies the stream, changing the color of subtitle text to fg
+// while keeping the alpha of each palette entry, so anti-aliased edges
+// stay smooth.
+//
+// Text is assumed to be lighter than its outline. The palette entries
+// used by the object of a display set, or all entries when it defines
+// no object, are considered text when their luma is at least threshold
+// of the way from the darkest to the lightest mostly opaque entry. A
+// threshold of 0.5 works well for light text with a dark outline; raise
+// it to recolor fewer of the blended edge entries.
+func Recolor(r *pgs.Reader, w *pgs.Writer, fg color.RGBA, threshold float64) error {
+	y, cb, cr := color.RGBToYCbCr(fg.R, fg.G, fg.B)
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ds.Palette != nil {
+			var used map[uint8]int
+			if ds.Object != nil {
+				used, err = ds.Object.UsedIndices()
+				if err != nil {
+					return fmt.Errorf("display set %d: object %d: %w", i, ds.Object.ID, err)
+				}
+			}
+			p := *ds.Palette
+			p.Entries = append([]pgs.PaletteEntry(nil), p.Entries...)
+			for _, j := range textEntries(&p, used, threshold) {
+				p.Entries[j].Y, p.Entries[j].Cb, p.Entries[j].Cr = y, cb, cr
+			}
+			ds.Palette = &p
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
+
+// textEntries returns the indices of the palette entries that are
+// classified as text. When used is nil, all entries are considered.
+func textEntries(p *pgs.Palette, used map[uint8]int, threshold float64) []int {
+	const opaque = 0x80
+	min, max := 0xff, 0
+	for _, e := range p.Entries {
+		if (used == nil || used[e.ID] != 0) && e.A >= opaque {
+			if int(e.Y) < min {
+				min = int(e.Y)
+			}
+			if int(e.Y) > max {
+				max = int(e.Y)
+			}
+		}
+	}
+	if min > max {
+		return nil
+	}
+	cutoff := float64(min) + threshold*float64(max-min)
+	var text []int
+	for i, e := range p.Entries {
+		if (used == nil || used[e.ID] != 0) && e.A != 0 && float64(e.Y) >= cutoff {
+			text = append(text, i)
+		}
+	}
+	return text
+}
@@ -0,0 +1,15 @@
+package trans
+
+import "github.com/andrewarchi/transup/pgs"
+
+// RenumberCompositions copies the stream, rewriting composition numbers
+// to count up from zero, as strict decoders require after display sets
+// are merged, split, or dropped. Composition states are unchanged.
+func RenumberCompositions(r *pgs.Reader, w *pgs.Writer) error {
+	var n uint16
+	return Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		ds.CompositionNumber = n
+		n++
+		return ds, nil
+	})
+}
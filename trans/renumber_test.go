@@ -0,0 +1,43 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestRenumberCompositions(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Epochs(2), pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range stream {
+		stream[i].CompositionNumber = uint16(100 + 7*i)
+	}
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := RenumberCompositions(pgs.NewReader(&b), pgs.NewWriter(&out)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(stream) {
+		t.Fatalf("got %d display sets, want %d", len(got), len(stream))
+	}
+	for i := range got {
+		if got[i].CompositionNumber != uint16(i) {
+			t.Errorf("display set %d: composition number %d", i, got[i].CompositionNumber)
+		}
+		if got[i].CompositionState != stream[i].CompositionState {
+			t.Errorf("display set %d: composition state %v, want %v", i, got[i].CompositionState, stream[i].CompositionState)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package trans
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Rescale copies the stream, scaling it to a new video resolution. The
+// composition dimensions, windows, and composition object positions and
+// crops are scaled proportionally, and each object bitmap is resampled
+// with the bilinear filter, which keeps anti-aliased edges smooth by
+// blending neighboring pixels before mapping them back to the palette.
+func Rescale(r *pgs.Reader, w *pgs.Writer, width, height int) error {
+	return rescale(r, w, width, height, pgs.Bilinear)
+}
+
+// RescaleFilter copies the stream, scaling it as Rescale, but with the
+// filter f, such as pgs.NearestNeighbor for bitmaps without anti-aliased
+// edges, which keeps their palettes exact.
+func RescaleFilter(r *pgs.Reader, w *pgs.Writer, width, height int, f pgs.Filter) error {
+	return rescale(r, w, width, height, f)
+}
+
+func rescale(r *pgs.Reader, w *pgs.Writer, width, height int, f pgs.Filter) error {
+	if width <= 0 || height <= 0 || width > 0xffff || height > 0xffff {
+		return fmt.Errorf("invalid dimensions: %dx%d", width, height)
+	}
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := scaleDisplaySet(ds, width, height, f); err != nil {
+			return fmt.Errorf("display set %d: %w", i, err)
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
+
+// scaleDisplaySet scales the display set in place to the new video
+// dimensions, resampling its objects with the palette it uses, which may
+// be defined earlier in the epoch.
+func scaleDisplaySet(ds *pgs.DisplaySet, width, height int, f pgs.Filter) error {
+	if ds.Width == 0 || ds.Height == 0 {
+		return fmt.Errorf("invalid video dimensions: %dx%d", ds.Width, ds.Height)
+	}
+	sx := func(v uint16) uint16 { return scaleCoord(v, width, int(ds.Width)) }
+	sy := func(v uint16) uint16 { return scaleCoord(v, height, int(ds.Height)) }
+	for i := range ds.Windows {
+		win := &ds.Windows[i]
+		win.X, win.Y = sx(win.X), sy(win.Y)
+		// Keep windows from collapsing to zero area
+		win.Width, win.Height = uint16(max(sx(win.Width), 1)), uint16(max(sy(win.Height), 1))
+		win.X, win.Width = clampSpan(win.X, win.Width, width)
+		win.Y, win.Height = clampSpan(win.Y, win.Height, height)
+	}
+	objects := make([]pgs.CompositionObject, len(ds.Objects))
+	for i, obj := range ds.Objects {
+		obj.X, obj.Y = sx(obj.X), sy(obj.Y)
+		if obj.Crop != nil {
+			crop := *obj.Crop
+			crop.X, crop.Y, crop.Width, crop.Height = sx(crop.X), sy(crop.Y), sx(crop.Width), sy(crop.Height)
+			crop.X, crop.Width = clampSpan(crop.X, crop.Width, width)
+			crop.Y, crop.Height = clampSpan(crop.Y, crop.Height, height)
+			obj.Crop = &crop
+		}
+		// Rounding may push objects past the frame, including those
+		// defined earlier in the epoch
+		if def := ds.ResolveObject(obj.ObjectID); def != nil {
+			obj.X, _ = clampSpan(obj.X, uint16(max(sx(def.Width), 1)), width)
+			obj.Y, _ = clampSpan(obj.Y, uint16(max(sy(def.Height), 1)), height)
+		}
+		objects[i] = obj
+	}
+	defined := ds.DefinedObjects()
+	p := ds.ResolvePalette()
+	scaled := make([]pgs.Object, len(defined))
+	for i, def := range defined {
+		if p == nil {
+			return fmt.Errorf("object %d: palette %d not defined in epoch", def.ID, ds.PaletteID)
+		}
+		obj := *def
+		ow, oh := max(int(sx(obj.Width)), 1), max(int(sy(obj.Height)), 1)
+		img, err := obj.Scale(p, ow, oh, f)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", obj.ID, err)
+		}
+		obj.Image = *img
+		obj.DataLen = len(img.Data)
+		scaled[i] = obj
+	}
+	ds.Objects = objects
+	if len(scaled) != 0 {
+		ds.Object, ds.ExtraObjects = &scaled[0], scaled[1:]
+	}
+	ds.Width, ds.Height = uint16(width), uint16(height)
+	return nil
+}
+
+// scaleCoord scales v by num/den, rounding to the nearest integer.
+func scaleCoord(v uint16, num, den int) uint16 {
+	n := (int(v)*num + den/2) / den
+	if n > 0xffff {
+		n = 0xffff
+	}
+	return uint16(n)
+}
+
+// clampSpan moves the span of n starting at v left to end within size,
+// shrinking it to size if longer, so that it stays on screen.
+func clampSpan(v, n uint16, size int) (uint16, uint16) {
+	if int(n) > size {
+		n = uint16(size)
+	}
+	if int(v)+int(n) > size {
+		v = uint16(size - int(n))
+	}
+	return v, n
+}
@@ -0,0 +1,76 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestRescale(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second subtitle uses the palette defined by the first
+	stream[2].Palette = nil
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Rescale(pgs.NewReader(&b), pgs.NewWriter(&out), 960, 540); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(stream) {
+		t.Fatalf("got %d display sets, want %d", len(got), len(stream))
+	}
+	for i := range got {
+		ds := &got[i]
+		if ds.Width != 960 || ds.Height != 540 {
+			t.Errorf("display set %d: frame %dx%d, want 960x540", i, ds.Width, ds.Height)
+		}
+		if ds.Object != nil && (ds.Object.Width != 100 || ds.Object.Height != 25) {
+			t.Errorf("display set %d: object %dx%d, want 100x25", i, ds.Object.Width, ds.Object.Height)
+		}
+		if err := ds.ValidateWindows(); err != nil {
+			t.Errorf("display set %d: %v", i, err)
+		}
+	}
+}
+
+func TestRescaleReusedObject(t *testing.T) {
+	sup := pgstest.BuildStream(pgstest.Subtitles(2), pgstest.ReuseObjects(), pgstest.FrameSize(4, 4), pgstest.ObjectSize(3, 1))
+	stream, err := pgs.NewReader(bytes.NewReader(sup)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second subtitle shows the object of the first at the right
+	// edge, where rounding would push it past the frame
+	stream[2].Objects[0].X = 1
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Rescale(pgs.NewReader(&b), pgs.NewWriter(&out), 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := got[0].Object.Width; w != 5 {
+		t.Fatalf("object %d pixels wide, want 5", w)
+	}
+	if co := got[2].Objects[0]; co.X != 1 {
+		t.Errorf("reused object at x %d, want 1 to end at the frame edge", co.X)
+	}
+}
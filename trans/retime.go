@@ -0,0 +1,89 @@
+package trans
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Retime copies the stream, mapping the presentation time of each display
+// set through fn, which must be nondecreasing, so that display sets stay
+// in order. The lead of each decoding time before its presentation time
+// is kept, rather than mapped, since decoders need the same time to
+// decode an object at any rate, and is clamped to the start of the clock.
+// Composition numbers and states are left unchanged, so epochs keep
+// their boundaries.
+func Retime(r *pgs.Reader, w *pgs.Writer, fn func(time.Duration) time.Duration) error {
+	prev := time.Duration(-1)
+	return Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		lead := ds.PresentationTime - ds.DecodingTime
+		t := fn(ds.PresentationTime)
+		if t < 0 {
+			return nil, fmt.Errorf("presentation time %s retimed before zero to %s", ds.PresentationTime, t)
+		}
+		if t < prev {
+			return nil, fmt.Errorf("presentation time %s retimed to %s, before previous %s", ds.PresentationTime, t, prev)
+		}
+		prev = t
+		ds.PresentationTime = t
+		ds.DecodingTime = max(t-lead, 0)
+		return ds, nil
+	})
+}
+
+// Shift copies the stream, adding offset, which may be negative, to the
+// times of all display sets, as with Retime.
+func Shift(r *pgs.Reader, w *pgs.Writer, offset time.Duration) error {
+	return Retime(r, w, func(t time.Duration) time.Duration {
+		return t + offset
+	})
+}
+
+// Stretch copies the stream, scaling the times of all display sets for
+// video converted from one frame rate to another, with the same frames,
+// such as 23.976 to 25 fps for PAL speedup, as with Retime.
+func Stretch(r *pgs.Reader, w *pgs.Writer, fromFPS, toFPS float64) error {
+	if !(fromFPS > 0) || !(toFPS > 0) {
+		return fmt.Errorf("invalid frame rates: %g to %g", fromFPS, toFPS)
+	}
+	return Retime(r, w, func(t time.Duration) time.Duration {
+		return time.Duration(float64(t) * fromFPS / toFPS)
+	})
+}
+
+// Anchor pairs a time in the source stream with the time it should be
+// retimed to.
+type Anchor struct {
+	From, To time.Duration
+}
+
+// AnchorMap returns a function for Retime that interpolates linearly
+// between anchors, which must be increasing in both times, and
+// extrapolates beyond the first and last anchors by the nearest segment.
+// A single anchor is a constant offset.
+func AnchorMap(anchors []Anchor) (func(time.Duration) time.Duration, error) {
+	if len(anchors) == 0 {
+		return nil, errors.New("no anchors")
+	}
+	for i := 1; i < len(anchors); i++ {
+		if anchors[i].From <= anchors[i-1].From || anchors[i].To <= anchors[i-1].To {
+			return nil, fmt.Errorf("anchor %d: %s->%s not after %s->%s", i,
+				anchors[i].From, anchors[i].To, anchors[i-1].From, anchors[i-1].To)
+		}
+	}
+	if len(anchors) == 1 {
+		offset := anchors[0].To - anchors[0].From
+		return func(t time.Duration) time.Duration { return t + offset }, nil
+	}
+	return func(t time.Duration) time.Duration {
+		i := 1
+		for i < len(anchors)-1 && t >= anchors[i].From {
+			i++
+		}
+		a, b := anchors[i-1], anchors[i]
+		scale := float64(b.To-a.To) / float64(b.From-a.From)
+		return a.To + time.Duration(float64(t-a.From)*scale)
+	}, nil
+}
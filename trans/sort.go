@@ -0,0 +1,88 @@
+package trans
+
+import (
+	"container/heap"
+	"io"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// SortByTime copies the stream, sorting the display sets stably by
+// presentation time and renumbering the compositions to count up from
+// zero. The whole stream is buffered, so memory use is proportional to
+// its size; use SortByTimeWindow for large files. It returns the number
+// of display sets moved earlier, those presented before a display set
+// preceding them in the stream.
+func SortByTime(r *pgs.Reader, w *pgs.Writer) (int, error) {
+	return SortByTimeWindow(r, w, 0)
+}
+
+// SortByTimeWindow is like SortByTime, but buffers at most window display
+// sets, so only display sets out of order by less than the window are
+// sorted into place. Those further out of order are written late, in the
+// order read. A window of 0 or less buffers the whole stream.
+func SortByTimeWindow(r *pgs.Reader, w *pgs.Writer, window int) (int, error) {
+	var buf displaySetHeap
+	var latest time.Duration
+	reordered, seq := 0, 0
+	var n uint16
+	write := func() error {
+		ds := heap.Pop(&buf).(sequenced).ds
+		ds.CompositionNumber = n
+		n++
+		return w.Write(ds)
+	}
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return reordered, err
+		}
+		if seq != 0 && ds.PresentationTime < latest {
+			reordered++
+		}
+		latest = max(latest, ds.PresentationTime)
+		heap.Push(&buf, sequenced{ds, seq})
+		seq++
+		if window > 0 && buf.Len() > window {
+			if err := write(); err != nil {
+				return reordered, err
+			}
+		}
+	}
+	for buf.Len() != 0 {
+		if err := write(); err != nil {
+			return reordered, err
+		}
+	}
+	return reordered, nil
+}
+
+// sequenced is a display set with its position in the stream.
+type sequenced struct {
+	ds  *pgs.DisplaySet
+	seq int
+}
+
+// displaySetHeap is a min-heap of display sets ordered by presentation
+// time, then position in the stream.
+type displaySetHeap []sequenced
+
+func (h displaySetHeap) Len() int { return len(h) }
+func (h displaySetHeap) Less(i, j int) bool {
+	if h[i].ds.PresentationTime != h[j].ds.PresentationTime {
+		return h[i].ds.PresentationTime < h[j].ds.PresentationTime
+	}
+	return h[i].seq < h[j].seq
+}
+func (h displaySetHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *displaySetHeap) Push(x any)   { *h = append(*h, x.(sequenced)) }
+func (h *displaySetHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,61 @@
+package trans
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestSortByTimeWindow(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(3)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Move the display set at 1s to the end, after those at 3s, 4s, 6s,
+	// 7s, and 9s, and the one at 7s before the one at 6s
+	order := []int{1, 2, 4, 3, 5, 0}
+	var in bytes.Buffer
+	w := pgs.NewWriter(&in)
+	for _, i := range order {
+		if err := w.Write(&stream[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		window int
+		want   []int
+	}{
+		{0, []int{1, 3, 4, 6, 7, 9}},
+		{2, []int{3, 4, 6, 1, 7, 9}},
+		{1, []int{3, 4, 6, 7, 1, 9}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		reordered, err := SortByTimeWindow(pgs.NewReader(bytes.NewReader(in.Bytes())), pgs.NewWriter(&out), tt.window)
+		if err != nil {
+			t.Fatalf("window %d: %v", tt.window, err)
+		}
+		if reordered != 2 {
+			t.Errorf("window %d: reordered %d display sets, want 2", tt.window, reordered)
+		}
+		sorted, err := pgs.NewReader(&out).ReadAll()
+		if err != nil {
+			t.Fatalf("window %d: %v", tt.window, err)
+		}
+		var got []int
+		for i, ds := range sorted {
+			got = append(got, int(ds.PresentationTime/time.Second))
+			if int(ds.CompositionNumber) != i {
+				t.Errorf("window %d: display set %d has composition number %d", tt.window, i, ds.CompositionNumber)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("window %d: got times %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
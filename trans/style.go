@@ -0,0 +1,68 @@
+package trans
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// AdjustPalettes copies the stream, applying the adjustment to every
+// palette, as with Palette.Adjust, such as to brighten dim subtitles or
+// remove a yellow tint.
+func AdjustPalettes(r *pgs.Reader, w *pgs.Writer, a pgs.PaletteAdjustment) error {
+	return Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		if ds.Palette != nil {
+			ds.Palette = ds.Palette.Adjust(a)
+		}
+		return ds, nil
+	})
+}
+
+// ForcedOnly copies the stream with only the composition objects flagged
+// as Forced, such as translations of foreign dialogue. Display sets left
+// showing nothing are dropped, unless they clear forced objects. When
+// display sets defining palettes, objects, or windows used later in the
+// epoch are dropped, the display sets using them are resolved to carry
+// them, and the first is made an EpochStart, as with Trim. Composition
+// numbers count up from zero. It returns the number of composition
+// objects dropped.
+func ForcedOnly(r *pgs.Reader, w *pgs.Writer) (int, error) {
+	out := &editOutput{w: w}
+	pt := &editPart{to: forever}
+	var epoch pgs.Epoch
+	dropped := 0
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return dropped, err
+		}
+		if ds.CompositionState == pgs.EpochStart {
+			epoch.DisplaySets = nil
+			out.carried, out.start = false, false
+		}
+		var objects []pgs.CompositionObject
+		for _, co := range ds.Objects {
+			if co.Forced {
+				objects = append(objects, co)
+			}
+		}
+		dropped += len(ds.Objects) - len(objects)
+		ds.Objects = objects
+		epoch.DisplaySets = append(epoch.DisplaySets, *ds)
+		if ds.IsClear() && (!out.started || out.prev.IsClear()) {
+			if ds.CompositionState == pgs.EpochStart {
+				out.start = true
+			}
+			out.carried = true
+			continue
+		}
+		if err := out.copy(&epoch, pt); err != nil {
+			return dropped, fmt.Errorf("display set %d: %w", i, err)
+		}
+	}
+	return dropped, out.flush()
+}
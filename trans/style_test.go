@@ -0,0 +1,48 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestForcedOnly(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(3)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the second subtitle is forced
+	stream[2].Objects[0].Forced = true
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	dropped, err := ForcedOnly(pgs.NewReader(&b), pgs.NewWriter(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 2 {
+		t.Errorf("dropped %d composition objects, want 2", dropped)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d display sets, want the forced subtitle and its clear", len(got))
+	}
+	show, clear := &got[0], &got[1]
+	if show.PresentationTime != stream[2].PresentationTime || len(show.Objects) != 1 || !show.Objects[0].Forced {
+		t.Errorf("got %d objects at %s, want the forced subtitle at %s", len(show.Objects), show.PresentationTime, stream[2].PresentationTime)
+	}
+	if show.CompositionState != pgs.EpochStart || show.Object == nil || show.Palette == nil {
+		t.Error("forced subtitle not made an EpochStart carrying its object and palette")
+	}
+	if !clear.IsClear() || clear.PresentationTime != stream[3].PresentationTime {
+		t.Errorf("display set at %s does not clear the forced subtitle", clear.PresentationTime)
+	}
+}
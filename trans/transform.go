@@ -0,0 +1,36 @@
+package trans
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// Transform copies the stream, passing each display set through fn and
+// writing the display set it returns, for writing custom transforms. The
+// display set may be modified in place. Returning nil drops the display
+// set and returning an error stops the copy. Like the other transforms
+// writing to a Writer, it writes nothing when w is in a dry run, as set
+// by pgs.Writer.DryRun, but still fails as it would otherwise.
+func Transform(r *pgs.Reader, w *pgs.Writer, fn func(*pgs.DisplaySet) (*pgs.DisplaySet, error)) error {
+	for i := 0; ; i++ {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ds, err = fn(ds)
+		if err != nil {
+			return fmt.Errorf("display set %d: %w", i, err)
+		}
+		if ds == nil {
+			continue
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
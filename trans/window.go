@@ -0,0 +1,112 @@
+package trans
+
+import (
+	"image"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// SingleWindow copies the stream, merging the windows of each display
+// set into one window with ID 0 covering all of them, for decoders that
+// handle multiple windows poorly. Composition object positions are in
+// frame coordinates, so they stay the same and only refer to the merged
+// window.
+func SingleWindow(r *pgs.Reader, w *pgs.Writer) error {
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(ds.Windows) > 1 {
+			var rect image.Rectangle
+			for _, win := range ds.Windows {
+				rect = rect.Union(win.Rect())
+			}
+			ds.Windows = []pgs.Window{{
+				X:      uint16(rect.Min.X),
+				Y:      uint16(rect.Min.Y),
+				Width:  uint16(rect.Dx()),
+				Height: uint16(rect.Dy()),
+			}}
+		} else if len(ds.Windows) == 1 {
+			ds.Windows[0].ID = 0
+		}
+		for i := range ds.Objects {
+			ds.Objects[i].WindowID = 0
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
+
+// RemoveWindow copies the stream, dropping the window with the given ID
+// and the composition objects placed in it, such as to strip a
+// watermark. The other windows are renumbered in order from zero, with
+// the composition objects placed in them, as some decoders require
+// contiguous window IDs. The definitions of objects placed only in the
+// dropped window are dropped from the display sets that place them. A
+// display set whose composition objects were all in the dropped window
+// clears the screen and drops its palettes, since nothing shown uses
+// them.
+func RemoveWindow(r *pgs.Reader, w *pgs.Writer, windowID uint8) error {
+	ids := make(map[uint8]uint8) // New IDs of the windows of the epoch
+	for {
+		ds, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(ds.Windows) != 0 {
+			clear(ids)
+			windows := ds.Windows[:0:0]
+			for _, win := range ds.Windows {
+				if win.ID != windowID {
+					ids[win.ID] = uint8(len(windows))
+					win.ID = uint8(len(windows))
+					windows = append(windows, win)
+				}
+			}
+			ds.Windows = windows
+		}
+		removed := make(map[uint16]bool) // Objects placed in the window
+		objects := ds.Objects[:0:0]
+		for _, co := range ds.Objects {
+			if co.WindowID == windowID {
+				removed[co.ObjectID] = true
+				continue
+			}
+			if id, ok := ids[co.WindowID]; ok {
+				co.WindowID = id
+			}
+			objects = append(objects, co)
+		}
+		for _, co := range objects {
+			delete(removed, co.ObjectID)
+		}
+		ds.Objects = objects
+		var defs []pgs.Object
+		for _, obj := range ds.DefinedObjects() {
+			if !removed[obj.ID] {
+				defs = append(defs, *obj)
+			}
+		}
+		ds.Object, ds.ExtraObjects = nil, nil
+		if len(defs) != 0 {
+			ds.Object, ds.ExtraObjects = &defs[0], defs[1:]
+		}
+		if len(objects) == 0 && len(removed) != 0 {
+			ds.Palette, ds.ExtraPalettes = nil, nil
+			ds.PaletteUpdate = false
+		}
+		if err := w.Write(ds); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package trans
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestRemoveWindow(t *testing.T) {
+	want, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(pgstest.Subtitles(2)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add a watermark in window 0 to each subtitle, moving the subtitles
+	// to window 1, and a display set showing only the watermark
+	mark := image.NewPaletted(image.Rect(0, 0, 8, 4), color.Palette{color.Transparent, color.White})
+	mark.Pix[0] = 1
+	wm, err := pgs.NewDisplaySet(mark, 10, 10, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm.CompositionState = pgs.Normal
+	wm.Object.ID, wm.Objects[0].ObjectID = 9, 9
+	var in []pgs.DisplaySet
+	for _, ds := range want {
+		ds.Windows = append([]pgs.Window(nil), ds.Windows...)
+		ds.Objects = append([]pgs.CompositionObject(nil), ds.Objects...)
+		for i := range ds.Windows {
+			ds.Windows[i].ID++
+		}
+		for i := range ds.Objects {
+			ds.Objects[i].WindowID++
+		}
+		if ds.Object != nil {
+			ds.Windows = append([]pgs.Window{wm.Windows[0]}, ds.Windows...)
+			ds.Objects = append(ds.Objects, wm.Objects[0])
+			ds.ExtraObjects = []pgs.Object{*wm.Object}
+		}
+		in = append(in, ds)
+	}
+	in = append(in, *wm)
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := RemoveWindow(pgs.NewReader(&b), pgs.NewWriter(&out), 0); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want)+1 {
+		t.Fatalf("got %d display sets, want %d", len(got), len(want)+1)
+	}
+	for i := range want {
+		g, w := &got[i], &want[i]
+		if !reflect.DeepEqual(g.Windows, w.Windows) || !reflect.DeepEqual(g.Objects, w.Objects) {
+			t.Errorf("display set %d: got windows %v and objects %v, want %v and %v", i, g.Windows, g.Objects, w.Windows, w.Objects)
+		}
+		if objs := g.DefinedObjects(); len(objs) != len(w.DefinedObjects()) || len(objs) != 0 && objs[0].ID != 0 {
+			t.Errorf("display set %d: got %d object definitions, want the subtitle only", i, len(objs))
+		}
+	}
+	last := &got[len(want)]
+	if !last.IsClear() || len(last.DefinedObjects()) != 0 || len(last.DefinedPalettes()) != 0 {
+		t.Errorf("watermark display set not cleared: %d objects, %d definitions, %d palettes",
+			len(last.Objects), len(last.DefinedObjects()), len(last.DefinedPalettes()))
+	}
+}
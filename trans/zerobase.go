@@ -0,0 +1,28 @@
+package trans
+
+import (
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+// ZeroBaseTimestamps copies the stream, subtracting the presentation time
+// of the first display set from the presentation and decoding times of
+// all display sets, so that the track starts at zero to align with video
+// that does. Decoding times that lead their presentation times by more
+// than the offset are clamped to zero. It returns the offset removed.
+func ZeroBaseTimestamps(r *pgs.Reader, w *pgs.Writer) (time.Duration, error) {
+	offset, first := time.Duration(0), true
+	err := Transform(r, w, func(ds *pgs.DisplaySet) (*pgs.DisplaySet, error) {
+		if first {
+			offset, first = ds.PresentationTime, false
+		}
+		ds.PresentationTime -= offset
+		ds.DecodingTime -= offset
+		if ds.DecodingTime < 0 {
+			ds.DecodingTime = 0
+		}
+		return ds, nil
+	})
+	return offset, err
+}
@@ -0,0 +1,48 @@
+package trans
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/andrewarchi/transup/pgs"
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestZeroBaseTimestamps(t *testing.T) {
+	stream, err := pgs.NewReader(bytes.NewReader(pgstest.BuildStream(
+		pgstest.Subtitles(2), pgstest.Timing(time.Hour, 2*time.Second, 3*time.Second)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decoding of the first subtitle leads its presentation
+	stream[0].DecodingTime -= 500 * time.Millisecond
+	var b bytes.Buffer
+	if err := pgs.NewWriter(&b).WriteAll(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	offset, err := ZeroBaseTimestamps(pgs.NewReader(&b), pgs.NewWriter(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != time.Hour {
+		t.Errorf("removed offset %s, want 1h", offset)
+	}
+	got, err := pgs.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(stream) {
+		t.Fatalf("got %d display sets, want %d", len(got), len(stream))
+	}
+	for i := range got {
+		if want := stream[i].PresentationTime - time.Hour; got[i].PresentationTime != want {
+			t.Errorf("display set %d: presented at %s, want %s", i, got[i].PresentationTime, want)
+		}
+		if want := max(stream[i].DecodingTime-time.Hour, 0); got[i].DecodingTime != want {
+			t.Errorf("display set %d: decoded at %s, want %s", i, got[i].DecodingTime, want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package ts
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+const (
+	m2tsPacketSize = 192 // BluRay packets, with a 4-byte timestamp prefix
+	langDescriptor = 0x0a
+)
+
+// PESReader demuxes a PGS stream carried in the PES packets of an MPEG
+// transport stream and reads it as a .sup stream, for use with
+// pgs.NewReader. Both 188-byte packets and the 192-byte packets of
+// BluRay M2TS files are accepted. The timestamps of the segments are the
+// PTS and DTS, truncated to 32 bits, of the PES packets in which they
+// begin, and segments may span PES packets. PSI sections are only read
+// when they fit in a single packet.
+type PESReader struct {
+	r       *bufio.Reader
+	pid     uint16 // 0 when not yet found
+	pmtPID  uint16 // 0 when not yet found
+	size    int    // Packet size, 0 when not yet detected
+	started bool   // Whether a PES packet has begun
+	pts     uint32
+	dts     uint32
+	header  int    // Bytes of the PES header left to skip
+	seg     []byte // Partial segment, with a .sup header
+	out     []byte // Complete segments not yet read
+	track   pgs.TrackInfo
+}
+
+// NewPESReader returns a reader of the PGS stream on the given PID. A PID
+// of 0 selects the first PGS stream in the program map table.
+func NewPESReader(r io.Reader, pid uint16) *PESReader {
+	return &PESReader{r: bufio.NewReaderSize(r, 2*m2tsPacketSize), pid: pid}
+}
+
+// PID returns the PID of the PGS stream, which is 0 until it is found
+// when selected from the program map table.
+func (pr *PESReader) PID() uint16 {
+	return pr.pid
+}
+
+// Track returns the metadata of the stream from the program map table,
+// once read.
+func (pr *PESReader) Track() pgs.TrackInfo {
+	return pr.track
+}
+
+func (pr *PESReader) Read(b []byte) (int, error) {
+	for len(pr.out) == 0 {
+		if err := pr.readPacket(); err != nil {
+			if err == io.EOF && len(pr.seg) != 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+	n := copy(b, pr.out)
+	pr.out = pr.out[n:]
+	return n, nil
+}
+
+func (pr *PESReader) readPacket() error {
+	if pr.size == 0 {
+		if err := pr.detectSize(); err != nil {
+			return err
+		}
+	}
+	p := make([]byte, pr.size)
+	if _, err := io.ReadFull(pr.r, p); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("truncated packet: %w", err)
+		}
+		return err
+	}
+	p = p[pr.size-packetSize:]
+	if p[0] != syncByte {
+		return fmt.Errorf("sync byte not 0x47: 0x%02x", p[0])
+	}
+	pusi := p[1]&0x40 != 0
+	pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+	payload := p[4:]
+	switch afc := p[3] >> 4 & 0x3; afc {
+	case 0x0, 0x2: // Adaptation field only
+		return nil
+	case 0x3:
+		if int(payload[0]) >= len(payload) {
+			return errors.New("adaptation field overflows packet")
+		}
+		payload = payload[1+int(payload[0]):]
+	}
+	switch {
+	case pid == patPID && pusi:
+		return pr.readPAT(payload)
+	case pid == pr.pmtPID && pr.pmtPID != 0 && pusi:
+		return pr.readPMT(payload)
+	case pid == pr.pid && pr.pid != 0:
+		return pr.readPES(pusi, payload)
+	}
+	return nil
+}
+
+// detectSize detects the packet size from the positions of sync bytes.
+func (pr *PESReader) detectSize() error {
+	b, err := pr.r.Peek(2 * m2tsPacketSize)
+	if len(b) == 0 && err == io.EOF {
+		return io.EOF
+	}
+	switch {
+	case len(b) >= packetSize+1 && b[0] == syncByte && b[packetSize] == syncByte,
+		len(b) == packetSize && b[0] == syncByte:
+		pr.size = packetSize
+	case len(b) >= m2tsPacketSize+5 && b[4] == syncByte && b[m2tsPacketSize+4] == syncByte,
+		len(b) == m2tsPacketSize && b[4] == syncByte:
+		pr.size = m2tsPacketSize
+	default:
+		return errors.New("not a transport stream")
+	}
+	return nil
+}
+
+// psiSection returns the section of a packet starting a PSI section,
+// without its CRC.
+func psiSection(payload []byte, tableID uint8) ([]byte, error) {
+	if len(payload) == 0 || 1+int(payload[0]) >= len(payload) {
+		return nil, errors.New("pointer field overflows packet")
+	}
+	s := payload[1+int(payload[0]):]
+	if len(s) < 3 || s[0] != tableID {
+		return nil, fmt.Errorf("table ID not 0x%02x", tableID)
+	}
+	length := 3 + int(binary.BigEndian.Uint16(s[1:3])&0x0fff)
+	if length > len(s) || length < 12 {
+		return nil, fmt.Errorf("section length %d does not fit packet", length)
+	}
+	if crc32MPEG(s[:length]) != 0 {
+		return nil, errors.New("section CRC mismatch")
+	}
+	return s[:length-4], nil
+}
+
+// readPAT reads the PID of the program map table of the first program.
+func (pr *PESReader) readPAT(payload []byte) error {
+	s, err := psiSection(payload, 0x00)
+	if err != nil {
+		return fmt.Errorf("program association table: %w", err)
+	}
+	for i := 8; i+4 <= len(s); i += 4 {
+		if binary.BigEndian.Uint16(s[i:]) != 0 { // Not the network PID
+			pr.pmtPID = binary.BigEndian.Uint16(s[i+2:]) & 0x1fff
+			return nil
+		}
+	}
+	return nil
+}
+
+// readPMT selects the first PGS stream, when the PID was not given, and
+// reads the language of the stream.
+func (pr *PESReader) readPMT(payload []byte) error {
+	s, err := psiSection(payload, 0x02)
+	if err != nil {
+		return fmt.Errorf("program map table: %w", err)
+	}
+	i := 12 + int(binary.BigEndian.Uint16(s[10:12])&0x0fff)
+	for i+5 <= len(s) {
+		typ := s[i]
+		pid := binary.BigEndian.Uint16(s[i+1:]) & 0x1fff
+		n := int(binary.BigEndian.Uint16(s[i+3:]) & 0x0fff)
+		if i+5+n > len(s) {
+			return errors.New("program map table: stream info overflows section")
+		}
+		info := s[i+5 : i+5+n]
+		if typ == streamTypePGS && pr.pid == 0 {
+			pr.pid = pid
+		}
+		if pid == pr.pid {
+			pr.track.Language = language(info)
+		}
+		i += 5 + n
+	}
+	return nil
+}
+
+// language returns the language code of an ISO 639 language descriptor
+// in the elementary stream info, if any.
+func language(info []byte) string {
+	for len(info) >= 2 {
+		tag, n := info[0], int(info[1])
+		if 2+n > len(info) {
+			break
+		}
+		if tag == langDescriptor && n >= 3 {
+			return string(info[2:5])
+		}
+		info = info[2+n:]
+	}
+	return ""
+}
+
+// readPES reads the payload of a packet of the PGS stream.
+func (pr *PESReader) readPES(pusi bool, payload []byte) error {
+	if pusi {
+		if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+			return errors.New("PES packet start code missing")
+		}
+		flags, n := payload[7], int(payload[8])
+		if 9+n > len(payload) {
+			return errors.New("PES header overflows packet")
+		}
+		h := payload[9 : 9+n]
+		if flags&0x80 != 0 && len(h) >= 5 {
+			pr.pts = uint32(readTimestamp(h))
+			pr.dts = pr.pts
+			if flags&0x40 != 0 && len(h) >= 10 {
+				pr.dts = uint32(readTimestamp(h[5:]))
+			}
+		}
+		pr.started = true
+		payload = payload[9+n:]
+	}
+	if !pr.started {
+		return nil // Continuation of a PES packet begun before the stream
+	}
+	for len(payload) != 0 {
+		if len(pr.seg) == 0 {
+			pr.seg = append(pr.seg, 'P', 'G')
+			pr.seg = binary.BigEndian.AppendUint32(pr.seg, pr.pts)
+			pr.seg = binary.BigEndian.AppendUint32(pr.seg, pr.dts)
+		}
+		k := min(pr.segmentLen()-len(pr.seg), len(payload))
+		pr.seg = append(pr.seg, payload[:k]...)
+		payload = payload[k:]
+		if len(pr.seg) >= supHeaderPrefix+3 && len(pr.seg) == pr.segmentLen() {
+			pr.out = append(pr.out, pr.seg...)
+			pr.seg = pr.seg[:0]
+		}
+	}
+	return nil
+}
+
+// segmentLen returns the length of the partial segment once complete, or
+// of its header, when its size has not yet been read.
+func (pr *PESReader) segmentLen() int {
+	if len(pr.seg) < supHeaderPrefix+3 {
+		return supHeaderPrefix + 3
+	}
+	return supHeaderPrefix + 3 + int(binary.BigEndian.Uint16(pr.seg[supHeaderPrefix+1:]))
+}
+
+// readTimestamp reads a 33-bit PES timestamp.
+func readTimestamp(b []byte) uint64 {
+	return uint64(b[0]>>1&0x7)<<30 | uint64(b[1])<<22 | uint64(b[2]>>1)<<15 |
+		uint64(b[3])<<7 | uint64(b[4]>>1)
+}
@@ -0,0 +1,229 @@
+// Package ts muxes and demuxes PGS streams carried in MPEG transport
+// streams.
+package ts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/andrewarchi/transup/pgs"
+)
+
+const (
+	packetSize = 188
+	syncByte   = 0x47
+
+	patPID = 0x0000
+	pmtPID = 0x0100
+
+	streamTypePGS   = 0x90 // Presentation graphic stream in BluRay
+	privateStream1  = 0xbd
+	pcrLead         = 9000 // 100ms at 90 kHz, between PCR and DTS
+	supHeaderPrefix = 10   // "PG", PTS, and DTS of .sup segment headers
+)
+
+// MuxToTS reads a .sup stream and writes it as a 188-byte packet MPEG
+// transport stream, with a program association table and program map
+// table, and each segment in a PES packet on the given PID. The PES
+// packets carry the PTS and DTS of the segments, and PCRs on the same PID
+// lead each DTS by 100ms. Segment payloads are passed through unchanged.
+func MuxToTS(r io.Reader, w io.Writer, pid uint16) error {
+	if pid < 0x0010 || pid > 0x1ffe || pid == pmtPID {
+		return fmt.Errorf("invalid PID: 0x%x", pid)
+	}
+	m := &muxer{w: w, cc: make(map[uint16]uint8)}
+	if err := m.writeSection(patPID, pat()); err != nil {
+		return err
+	}
+	if err := m.writeSection(pmtPID, pmt(pid)); err != nil {
+		return err
+	}
+	for {
+		ds, err := pgs.ReadDisplaySetBytes(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for len(ds) != 0 {
+			size := 13 + int(binary.BigEndian.Uint16(ds[11:13]))
+			seg := ds[:size]
+			pts := uint64(binary.BigEndian.Uint32(seg[2:6]))
+			dts := uint64(binary.BigEndian.Uint32(seg[6:10]))
+			if err := m.writePES(pid, pts, dts, seg[supHeaderPrefix:]); err != nil {
+				return err
+			}
+			ds = ds[size:]
+		}
+	}
+}
+
+type muxer struct {
+	w  io.Writer
+	cc map[uint16]uint8 // Continuity counters by PID
+}
+
+// writeSection writes a PSI section in a single packet.
+func (m *muxer) writeSection(pid uint16, section []byte) error {
+	payload := append([]byte{0}, section...) // Pointer field
+	for len(payload) < packetSize-4 {
+		payload = append(payload, 0xff)
+	}
+	return m.writePacket(pid, true, nil, payload)
+}
+
+// writePES writes a PES packet split into transport stream packets, with
+// a PCR in the first.
+func (m *muxer) writePES(pid uint16, pts, dts uint64, data []byte) error {
+	var pes []byte
+	if pts == dts {
+		pes = []byte{0, 0, 1, privateStream1, 0, 0, 0x84, 0x80, 5}
+		pes = appendTimestamp(pes, 0x2, pts)
+	} else {
+		pes = []byte{0, 0, 1, privateStream1, 0, 0, 0x84, 0xc0, 10}
+		pes = appendTimestamp(pes, 0x3, pts)
+		pes = appendTimestamp(pes, 0x1, dts)
+	}
+	pes = append(pes, data...)
+	if len(pes)-6 > 0xffff {
+		return fmt.Errorf("PES packet length overflow: %d", len(pes)-6)
+	}
+	binary.BigEndian.PutUint16(pes[4:6], uint16(len(pes)-6))
+
+	pcr := uint64(0)
+	if dts > pcrLead {
+		pcr = dts - pcrLead
+	}
+	first := true
+	for len(pes) != 0 {
+		var af []byte
+		if first {
+			af = pcrField(pcr)
+		}
+		space := packetSize - 4
+		if af != nil {
+			space -= 1 + len(af)
+		}
+		if k := space - len(pes); k > 0 {
+			af = stuff(af, k)
+			space = len(pes)
+		}
+		if err := m.writePacket(pid, first, af, pes[:space]); err != nil {
+			return err
+		}
+		pes = pes[space:]
+		first = false
+	}
+	return nil
+}
+
+// writePacket writes a transport stream packet with an optional
+// adaptation field, excluding its length byte, and payload.
+func (m *muxer) writePacket(pid uint16, pusi bool, af, payload []byte) error {
+	p := make([]byte, 4, packetSize)
+	p[0] = syncByte
+	p[1] = uint8(pid >> 8)
+	if pusi {
+		p[1] |= 0x40
+	}
+	p[2] = uint8(pid)
+	p[3] = 0x10 | m.cc[pid] // Payload only
+	if af != nil {
+		p[3] |= 0x20
+		p = append(p, uint8(len(af)))
+		p = append(p, af...)
+	}
+	p = append(p, payload...)
+	if len(p) != packetSize {
+		return fmt.Errorf("packet size %d", len(p))
+	}
+	m.cc[pid] = (m.cc[pid] + 1) & 0xf
+	_, err := m.w.Write(p)
+	return err
+}
+
+// pcrField returns an adaptation field with a PCR.
+func pcrField(pcr uint64) []byte {
+	return []byte{
+		0x10, // PCR flag
+		uint8(pcr >> 25), uint8(pcr >> 17), uint8(pcr >> 9), uint8(pcr >> 1),
+		uint8(pcr<<7) | 0x7e, 0, // Reserved bits and zero extension
+	}
+}
+
+// stuff grows an adaptation field to take k more bytes of the packet,
+// creating it when nil.
+func stuff(af []byte, k int) []byte {
+	if af == nil {
+		af = []byte{}
+		if k--; k == 0 { // Length byte alone
+			return af
+		}
+		af = append(af, 0) // No flags
+		k--
+	}
+	for i := 0; i < k; i++ {
+		af = append(af, 0xff)
+	}
+	return af
+}
+
+// appendTimestamp appends a 33-bit PES timestamp with a 4-bit prefix.
+func appendTimestamp(b []byte, prefix uint8, ts uint64) []byte {
+	return append(b,
+		prefix<<4|uint8(ts>>29)&0x0e|1,
+		uint8(ts>>22),
+		uint8(ts>>14)|1,
+		uint8(ts>>7),
+		uint8(ts<<1)|1)
+}
+
+func pat() []byte {
+	return section(0x00, 1, []byte{
+		0x00, 0x01, // Program number
+		0xe0 | pmtPID>>8, pmtPID & 0xff,
+	})
+}
+
+func pmt(pid uint16) []byte {
+	return section(0x02, 1, []byte{
+		0xe0 | uint8(pid>>8), uint8(pid), // PCR PID
+		0xf0, 0x00, // Program info length
+		streamTypePGS,
+		0xe0 | uint8(pid>>8), uint8(pid),
+		0xf0, 0x00, // ES info length
+	})
+}
+
+// section builds a PSI section with the long syntax and a CRC.
+func section(tableID uint8, id uint16, data []byte) []byte {
+	length := 5 + len(data) + 4
+	s := []byte{
+		tableID,
+		0xb0 | uint8(length>>8), uint8(length),
+		uint8(id >> 8), uint8(id),
+		0xc1,       // Version 0, current
+		0x00, 0x00, // Section number and last section number
+	}
+	s = append(s, data...)
+	crc := crc32MPEG(s)
+	return append(s, uint8(crc>>24), uint8(crc>>16), uint8(crc>>8), uint8(crc))
+}
+
+// crc32MPEG computes the CRC-32/MPEG-2 checksum used by PSI sections.
+func crc32MPEG(b []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, c := range b {
+		crc ^= uint32(c) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
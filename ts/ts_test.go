@@ -0,0 +1,32 @@
+package ts
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/andrewarchi/transup/pgs/pgstest"
+)
+
+func TestMuxDemuxRoundTrip(t *testing.T) {
+	// Objects larger than a packet span several packets
+	sup := pgstest.BuildStream(pgstest.Subtitles(3), pgstest.ObjectSize(400, 100))
+	var b bytes.Buffer
+	if err := MuxToTS(bytes.NewReader(sup), &b, 0x1200); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len()%188 != 0 {
+		t.Errorf("transport stream of %d bytes not in 188-byte packets", b.Len())
+	}
+	pr := NewPESReader(&b, 0)
+	got, err := io.ReadAll(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.PID() != 0x1200 {
+		t.Errorf("found PID 0x%x, want 0x1200", pr.PID())
+	}
+	if !bytes.Equal(got, sup) {
+		t.Errorf("demuxed %d bytes differing from the %d bytes muxed", len(got), len(sup))
+	}
+}